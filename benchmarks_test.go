@@ -0,0 +1,106 @@
+package log_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/fastjson"
+)
+
+func BenchmarkLogDisabled(b *testing.B) {
+	logger := log.WithLevels(log.New(log.Config{
+		Threshold: log.ErrorLevel,
+		Encoder:   json.NewEncoder(ioutil.Discard),
+	}))
+
+	b.Run("Data", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logger.Trace(log.Data{
+				"request_id": "abc123",
+				"attempt":    i,
+				"pi":         3.14,
+			})
+		}
+	})
+	b.Run("Enabled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if !log.Enabled(logger, log.TraceLevel) {
+				continue
+			}
+			logger.Trace(log.Data{
+				"request_id": "abc123",
+				"attempt":    i,
+				"pi":         3.14,
+			})
+		}
+	})
+}
+
+func BenchmarkLogEnabled(b *testing.B) {
+	logger := log.WithLevels(log.New(log.Config{
+		Threshold: log.TraceLevel,
+		Encoder:   json.NewEncoder(ioutil.Discard),
+	}))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !log.Enabled(logger, log.TraceLevel) {
+			continue
+		}
+		logger.Trace(log.Data{
+			"request_id": "abc123",
+			"attempt":    i,
+			"pi":         3.14,
+		})
+	}
+}
+
+func BenchmarkLogFields(b *testing.B) {
+	b.Run("Data", func(b *testing.B) {
+		logger := log.New(log.Config{
+			Threshold: log.TraceLevel,
+			Encoder:   fastjson.NewEncoder(ioutil.Discard),
+		})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logger.Log(log.TraceLevel, log.Data{
+				"request_id": "abc123",
+				"attempt":    i,
+				"pi":         3.14,
+			})
+		}
+	})
+	b.Run("Fields", func(b *testing.B) {
+		logger := log.New(log.Config{
+			Threshold: log.TraceLevel,
+			Encoder:   fastjson.NewEncoder(ioutil.Discard),
+		})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			log.LogFields(logger, log.TraceLevel,
+				log.String("request_id", "abc123"),
+				log.Int("attempt", i),
+				log.Float64("pi", 3.14),
+			)
+		}
+	})
+	b.Run("Fields with Filters", func(b *testing.B) {
+		logger := log.New(log.Config{
+			Threshold: log.TraceLevel,
+			Encoder:   fastjson.NewEncoder(ioutil.Discard),
+			Filters:   []log.Filter{log.DefaultFilter},
+		})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			log.LogFields(logger, log.TraceLevel,
+				log.String("request_id", "abc123"),
+				log.Int("attempt", i),
+				log.Float64("pi", 3.14),
+			)
+		}
+	})
+}
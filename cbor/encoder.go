@@ -0,0 +1,207 @@
+// Package cbor provides an Encoder that writes log Data as CBOR (RFC 8949), a
+// compact binary alternative to JSON for high-volume pipelines.
+package cbor
+
+import (
+	"encoding"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/internal/binenc"
+)
+
+var _ log.Encoder = &Encoder{}
+
+// Major types of a CBOR data item.
+const (
+	majorUint   = 0 << 5
+	majorNegInt = 1 << 5
+	majorBytes  = 2 << 5
+	majorText   = 3 << 5
+	majorArray  = 4 << 5
+	majorMap    = 5 << 5
+	majorTag    = 6 << 5
+)
+
+// Simple values and float headers of major type 7.
+const (
+	cborFalse   = 0xf4
+	cborTrue    = 0xf5
+	cborNull    = 0xf6
+	cborFloat32 = 0xfa
+	cborFloat64 = 0xfb
+
+	tagEpochTime = 1
+)
+
+// Encoder writes values to an output stream as CBOR. Levels and other
+// encoding.TextMarshalers are written as text strings, errors as their
+// messages, and time.Time as an epoch-based date/time (tag 1). Other values
+// are written as their JSON encoding would be.
+type Encoder struct {
+	w *binenc.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: binenc.NewWriter(w)}
+}
+
+// SetLengthPrefix sets whether each value is preceded by its length as a
+// 4-byte big-endian integer.
+func (enc *Encoder) SetLengthPrefix(prefix bool) {
+	enc.w.SetLengthPrefix(prefix)
+}
+
+// Encode writes the CBOR encoding of v.
+func (enc *Encoder) Encode(v interface{}) error {
+	return enc.w.WriteValue(v, func(b []byte, v interface{}) ([]byte, error) {
+		return appendValue(b, v, 0)
+	})
+}
+
+func appendValue(b []byte, v interface{}, depth int) ([]byte, error) {
+	if depth > binenc.MaxDepth {
+		return appendFallback(b, v)
+	}
+	switch val := v.(type) {
+	case nil:
+		return append(b, cborNull), nil
+	case bool:
+		if val {
+			return append(b, cborTrue), nil
+		}
+		return append(b, cborFalse), nil
+	case string:
+		return append(appendHead(b, majorText, uint64(len(val))), val...), nil
+	case []byte:
+		return append(appendHead(b, majorBytes, uint64(len(val))), val...), nil
+	case int:
+		return appendInt(b, int64(val)), nil
+	case int8:
+		return appendInt(b, int64(val)), nil
+	case int16:
+		return appendInt(b, int64(val)), nil
+	case int32:
+		return appendInt(b, int64(val)), nil
+	case int64:
+		return appendInt(b, val), nil
+	case uint:
+		return appendHead(b, majorUint, uint64(val)), nil
+	case uint8:
+		return appendHead(b, majorUint, uint64(val)), nil
+	case uint16:
+		return appendHead(b, majorUint, uint64(val)), nil
+	case uint32:
+		return appendHead(b, majorUint, uint64(val)), nil
+	case uint64:
+		return appendHead(b, majorUint, val), nil
+	case float32:
+		b = append(b, cborFloat32)
+		return binenc.AppendUint32(b, math.Float32bits(val)), nil
+	case float64:
+		return appendFloat64(b, val), nil
+	case log.Data:
+		return appendMap(b, val, depth)
+	case map[string]interface{}:
+		return appendMap(b, val, depth)
+	case []interface{}:
+		b = appendHead(b, majorArray, uint64(len(val)))
+		var err error
+		for _, item := range val {
+			if b, err = appendValue(b, item, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case time.Time:
+		return appendTime(b, val), nil
+	case log.Lazy:
+		if val == nil {
+			return append(b, cborNull), nil
+		}
+		return appendValue(b, val(), depth+1)
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return appendInt(b, i), nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return b, err
+		}
+		return appendFloat64(b, f), nil
+	case error:
+		return appendValue(b, val.Error(), depth)
+	case json.Marshaler:
+		return appendFallback(b, v)
+	case encoding.TextMarshaler:
+		txt, err := val.MarshalText()
+		if err != nil {
+			return b, err
+		}
+		return appendValue(b, string(txt), depth)
+	}
+	return appendFallback(b, v)
+}
+
+// appendFallback writes the value of v's JSON encoding, for types without a
+// CBOR representation of their own.
+func appendFallback(b []byte, v interface{}) ([]byte, error) {
+	decoded, err := binenc.DecodeJSON(v)
+	if err != nil {
+		return b, err
+	}
+	return appendValue(b, decoded, 0)
+}
+
+func appendMap(b []byte, m map[string]interface{}, depth int) ([]byte, error) {
+	b = appendHead(b, majorMap, uint64(len(m)))
+	var err error
+	for k, v := range m {
+		b = append(appendHead(b, majorText, uint64(len(k))), k...)
+		if b, err = appendValue(b, v, depth+1); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// appendHead writes the initial byte of a data item with its argument, using
+// the shortest encoding.
+func appendHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(b, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return binenc.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binenc.AppendUint64(append(b, major|27), n)
+}
+
+func appendInt(b []byte, i int64) []byte {
+	if i >= 0 {
+		return appendHead(b, majorUint, uint64(i))
+	}
+	return appendHead(b, majorNegInt, uint64(-1-i))
+}
+
+func appendFloat64(b []byte, f float64) []byte {
+	return binenc.AppendUint64(append(b, cborFloat64), math.Float64bits(f))
+}
+
+// appendTime writes t as seconds since the epoch, as an integer when there is
+// no fractional part.
+func appendTime(b []byte, t time.Time) []byte {
+	b = appendHead(b, majorTag, tagEpochTime)
+	if t.Nanosecond() == 0 {
+		return appendInt(b, t.Unix())
+	}
+	return appendFloat64(b, float64(t.UnixNano())/float64(time.Second))
+}
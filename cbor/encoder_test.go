@@ -0,0 +1,113 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/cbor"
+)
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input interface{}
+		want  string // hex, mostly from RFC 8949 Appendix A
+	}{
+		{"nil", nil, "f6"},
+		{"true", true, "f5"},
+		{"false", false, "f4"},
+		{"0", 0, "00"},
+		{"23", 23, "17"},
+		{"24", 24, "1818"},
+		{"1000", uint16(1000), "1903e8"},
+		{"1000000", int64(1000000), "1a000f4240"},
+		{"1000000000000", uint64(1000000000000), "1b000000e8d4a51000"},
+		{"-1", -1, "20"},
+		{"-100", int8(-100), "3863"},
+		{"-1000", -1000, "3903e7"},
+		{"float32", float32(100000.0), "fa47c35000"},
+		{"float64", 1.1, "fb3ff199999999999a"},
+		{"text", "a", "6161"},
+		{"bytes", []byte{1, 2, 3, 4}, "4401020304"},
+		{"array", []interface{}{1, 2, 3}, "83010203"},
+		{"data", log.Data{"a": 1}, "a1616101"},
+		{"map", map[string]interface{}{"a": nil}, "a16161f6"},
+		{"level", log.ErrorLevel, "654572726f72"},
+		{"error", fmt.Errorf("no"), "626e6f"},
+		{"epoch time", time.Unix(1363896240, 0), "c11a514b67b0"},
+		{"epoch time with fraction", time.Unix(1363896240, 500000000), "c1fb41d452d9ec200000"},
+		{"lazy", log.Lazy(func() interface{} { return "a" }), "6161"},
+		{"struct fallback", struct{ A int }{1}, "a1614101"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := cbor.NewEncoder(&buf).Encode(tc.input); err != nil {
+				t.Fatalf("Encode(%+v) returned unexpected error: %+v", tc.input, err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != tc.want {
+				t.Errorf("Encode(%+v) wrote %s, expected %s", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodeLengthPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	enc := cbor.NewEncoder(&buf)
+	enc.SetLengthPrefix(true)
+	if err := enc.Encode(log.Data{"a": 1}); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	if err := enc.Encode("a"); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	if got, want := hex.EncodeToString(buf.Bytes()), "00000004a1616101"+"000000026161"; got != want {
+		t.Errorf("Encode with length prefix wrote %s, expected %s", got, want)
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := cbor.NewEncoder(&buf).Encode(log.Data{"ch": make(chan int)}); err == nil {
+		t.Fatalf("Encode(<chan>) did not return an error")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Encode(<chan>) wrote %x despite an error", buf.Bytes())
+	}
+}
+
+func TestEncodeCycle(t *testing.T) {
+	data := log.Data{"a": 1}
+	data["self"] = data
+
+	var buf bytes.Buffer
+	if err := cbor.NewEncoder(&buf).Encode(data); err == nil {
+		t.Fatalf("Encode(<cycle>) did not return an error")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Encode(<cycle>) wrote %x despite an error", buf.Bytes())
+	}
+}
+
+func TestEncodeDeep(t *testing.T) {
+	var deep interface{} = "bottom"
+	for i := 0; i < 200; i++ {
+		deep = []interface{}{deep}
+	}
+
+	var buf bytes.Buffer
+	if err := cbor.NewEncoder(&buf).Encode(deep); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	// 200 one-item array heads, then the string head and "bottom"
+	if got := buf.Bytes(); len(got) != 207 || !bytes.HasSuffix(got, []byte("bottom")) {
+		t.Errorf("Encode wrote %x, expected 200 nested arrays around \"bottom\"", got)
+	}
+}
@@ -0,0 +1,211 @@
+// Command gelfcat sends JSON log lines to graylog. Each line read from the
+// files named, or from standard input, is decoded as log Data and sent as a
+// GELF message, so that files written by a JSON Encoder can be replayed.
+// With -text, lines that are not JSON are sent as the message itself.
+//
+//	echo "deploy finished" | gelfcat -text -transport tcp -addr graylog:12201
+//
+// gelfcat exits with a non-zero status if any line could not be sent.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/graylog"
+)
+
+// options are set from the command line flags.
+type options struct {
+	transport string
+	addr      string
+	host      string
+	text      bool
+	compress  string
+	level     int
+	chunkSize int
+	tls       bool
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.transport, "transport", "udp", "transport to send with, udp, tcp, or http")
+	flag.StringVar(&o.addr, "addr", "127.0.0.1:12201", "address of the graylog input, or URL for http")
+	flag.StringVar(&o.host, "host", "", "host for messages without one, the hostname by default")
+	flag.BoolVar(&o.text, "text", false, "send lines that are not JSON as the message")
+	flag.StringVar(&o.compress, "compress", "", "compression, gzip, zlib, or none; gzip by default for udp")
+	flag.IntVar(&o.level, "level", gzip.DefaultCompression, "compression level")
+	flag.IntVar(&o.chunkSize, "chunk-size", graylog.ChunkSizeWAN, "largest udp packet payload")
+	flag.BoolVar(&o.tls, "tls", false, "connect to the tcp input with TLS")
+	flag.Parse()
+
+	w, closeWriter, err := newWriter(o)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gelfcat: %+v\n", err)
+		os.Exit(2)
+	}
+	enc := graylog.NewEncoder(w, o.host)
+
+	var sent, failed int
+	inputs := flag.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	for _, name := range inputs {
+		s, f, err := sendFile(enc, name, o.text)
+		sent, failed = sent+s, failed+f
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gelfcat: %+v\n", err)
+			failed++
+		}
+	}
+	if err := closeWriter(); err != nil {
+		fmt.Fprintf(os.Stderr, "gelfcat: closing connection: %+v\n", err)
+		failed++
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "gelfcat: %d sent, %d failed\n", sent, failed)
+		os.Exit(1)
+	}
+}
+
+// newWriter creates the graylog client for the transport, along with a
+// function that sends anything buffered and closes it.
+func newWriter(o options) (io.Writer, func() error, error) {
+	switch o.transport {
+	case "udp":
+		compression := graylog.CompressGzip
+		switch o.compress {
+		case "", "gzip":
+		case "zlib":
+			compression = graylog.CompressZlib
+		case "none":
+			compression = graylog.CompressNone
+		default:
+			return nil, nil, fmt.Errorf("compression %q is not a valid udp compression", o.compress)
+		}
+		addr, err := net.ResolveUDPAddr("udp", o.addr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resolving udp address: %+v", err)
+		}
+		conn, err := net.ListenPacket("udp", ":0")
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening udp connection: %+v", err)
+		}
+		gl, err := graylog.New(graylog.Config{
+			Compression:      compression,
+			CompressionLevel: o.level,
+			ChunkSize:        o.chunkSize,
+			ServerAddr:       addr,
+			ClientPacketConn: conn,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		return gl, conn.Close, nil
+
+	case "tcp":
+		if o.compress != "" && o.compress != "none" {
+			return nil, nil, fmt.Errorf("compression %q is not a valid tcp compression", o.compress)
+		}
+		c := graylog.TCPConfig{ServerAddr: o.addr}
+		if o.tls {
+			c.TLSConfig = &tls.Config{}
+		}
+		tc, err := graylog.NewTCP(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tc, tc.Close, nil
+
+	case "http":
+		c := graylog.HTTPConfig{URL: o.addr, CompressionLevel: o.level}
+		if !strings.Contains(c.URL, "://") {
+			c.URL = "http://" + c.URL + "/gelf"
+		}
+		switch o.compress {
+		case "", "none":
+		case "gzip":
+			c.Compress = true
+		default:
+			return nil, nil, fmt.Errorf("compression %q is not a valid http compression", o.compress)
+		}
+		hc, err := graylog.NewHTTP(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		return hc, hc.Close, nil
+	}
+	return nil, nil, fmt.Errorf("transport %q is not a valid transport", o.transport)
+}
+
+// sendFile sends the lines of the named file, or standard input for "-".
+func sendFile(enc log.Encoder, name string, text bool) (sent, failed int, err error) {
+	if name == "-" {
+		return send(enc, os.Stdin, "stdin", text)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, 0, fmt.Errorf("opening input: %+v", err)
+	}
+	defer f.Close()
+	return send(enc, f, name, text)
+}
+
+// send encodes each non-blank line of r, reporting lines that cannot be
+// decoded or sent on standard error. The error returned is from reading r.
+func send(enc log.Encoder, r io.Reader, name string, text bool) (sent, failed int, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		data, err := decodeLine(line, text)
+		if err == nil {
+			err = enc.Encode(data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gelfcat: %s:%d: %+v\n", name, n, err)
+			failed++
+			continue
+		}
+		sent++
+	}
+	if err := scanner.Err(); err != nil {
+		return sent, failed, fmt.Errorf("reading %s: %+v", name, err)
+	}
+	return sent, failed, nil
+}
+
+// decodeLine decodes a JSON object, or when text is set, wraps a line that is
+// not one as the message.
+func decodeLine(line []byte, text bool) (log.Data, error) {
+	var data log.Data
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	err := dec.Decode(&data)
+	if err == nil && data != nil && !dec.More() {
+		return data, nil
+	}
+	if text {
+		return log.Data{log.MessageKey: string(line)}, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("expected a single JSON object")
+	}
+	return nil, fmt.Errorf("decoding line: %+v", err)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/graylog"
+	"github.com/PermissionData/log/graylog/server"
+)
+
+func TestDecodeLine(t *testing.T) {
+	testCases := []struct {
+		name    string
+		line    string
+		text    bool
+		want    log.Data
+		wantErr bool
+	}{
+		{
+			name: "json",
+			line: `{"message":"hi","n":1}`,
+			want: log.Data{"message": "hi", "n": json.Number("1")},
+		},
+		{name: "text", line: "plain words", text: true, want: log.Data{log.MessageKey: "plain words"}},
+		{name: "text required", line: "plain words", wantErr: true},
+		{name: "not an object", line: `"hi"`, wantErr: true},
+		{name: "null", line: `null`, wantErr: true},
+		{name: "trailing data", line: `{"a":1} {"b":2}`, wantErr: true},
+		{name: "trailing data as text", line: `{"a":1} x`, text: true, want: log.Data{log.MessageKey: `{"a":1} x`}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decodeLine([]byte(tc.line), tc.text)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("decodeLine returned error %v, expected error %t", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("decodeLine returned %v, expected %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	testCases := []struct {
+		name      string
+		transport string
+		compress  string
+		listen    func(string, server.Config) (*server.Server, error)
+	}{
+		{"udp gzip", "udp", "", server.ListenUDP},
+		{"udp zlib", "udp", "zlib", server.ListenUDP},
+		{"udp none", "udp", "none", server.ListenUDP},
+		{"tcp", "tcp", "", server.ListenTCP},
+		{"http", "http", "", server.ListenHTTP},
+		{"http gzip", "http", "gzip", server.ListenHTTP},
+	}
+
+	input := strings.Join([]string{
+		`{"message":"user logged in","log_level":"Error","user":"jdoe"}`,
+		``,
+		`not json`,
+		`{"message":"done"}`,
+	}, "\n")
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := tc.listen("127.0.0.1:0", server.Config{})
+			if err != nil {
+				t.Fatalf("unexpected error listening: %+v", err)
+			}
+			defer s.Close()
+
+			w, closeWriter, err := newWriter(options{
+				transport: tc.transport,
+				addr:      s.Addr().String(),
+				compress:  tc.compress,
+				level:     -1,
+				chunkSize: graylog.ChunkSizeWAN,
+			})
+			if err != nil {
+				t.Fatalf("newWriter returned unexpected error: %+v", err)
+			}
+			sent, failed, err := send(graylog.NewEncoder(w, "web-01"), strings.NewReader(input), "input", false)
+			if err != nil {
+				t.Fatalf("send returned unexpected error: %+v", err)
+			}
+			if err := closeWriter(); err != nil {
+				t.Fatalf("closing returned unexpected error: %+v", err)
+			}
+			if sent != 2 || failed != 1 {
+				t.Errorf("send returned %d sent and %d failed, expected 2 and 1", sent, failed)
+			}
+
+			for _, want := range []struct {
+				msg   string
+				level int
+			}{{"user logged in", 3}, {"done", 6}} {
+				select {
+				case msg := <-s.Messages():
+					if msg.ShortMessage != want.msg || msg.Level != want.level || msg.Host != "web-01" {
+						t.Errorf("received %+v, expected %q at level %d", msg, want.msg, want.level)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("timed out waiting for %q", want.msg)
+				}
+			}
+		})
+	}
+}
+
+func TestNewWriterInvalid(t *testing.T) {
+	testCases := []options{
+		{transport: "smoke", addr: "127.0.0.1:12201"},
+		{transport: "udp", addr: "127.0.0.1:12201", compress: "lz4", chunkSize: graylog.ChunkSizeWAN},
+		{transport: "udp", addr: "127.0.0.1:12201", chunkSize: 1},
+		{transport: "tcp", addr: "127.0.0.1:12201", compress: "gzip"},
+		{transport: "http", addr: "127.0.0.1:12201", compress: "zlib"},
+	}
+
+	for _, o := range testCases {
+		if _, _, err := newWriter(o); err == nil {
+			t.Errorf("newWriter(%+v) returned no error", o)
+		}
+	}
+}
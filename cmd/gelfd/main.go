@@ -0,0 +1,165 @@
+// Command gelfd receives GELF messages over UDP, TCP, and HTTP, and prints
+// them, so that what a graylog.Client sends can be seen without a graylog
+// server. Messages are pretty-printed by default, or written as JSON lines
+// with -format json, and may also be appended to a file with -out.
+//
+//	gelfd -udp :12201 -tcp :12201 -http :12202
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/console"
+	"github.com/PermissionData/log/graylog/server"
+)
+
+func main() {
+	var (
+		udpAddr  = flag.String("udp", ":12201", "UDP address to receive GELF on, empty to disable")
+		tcpAddr  = flag.String("tcp", "", "TCP address to receive null delimited GELF on")
+		httpAddr = flag.String("http", "", "HTTP address to receive GELF posted to /gelf on")
+		format   = flag.String("format", "pretty", "output format, pretty or json")
+		out      = flag.String("out", "", "file to also append messages to as JSON lines")
+	)
+	flag.Parse()
+
+	if err := run(*udpAddr, *tcpAddr, *httpAddr, *format, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "gelfd: %+v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(udpAddr, tcpAddr, httpAddr, format, out string) error {
+	var emit func(*server.Message) error
+	switch format {
+	case "pretty":
+		enc := console.NewEncoder(os.Stdout)
+		emit = func(msg *server.Message) error { return enc.Encode(messageData(msg)) }
+	case "json":
+		emit = func(msg *server.Message) error { return writeLine(os.Stdout, msg) }
+	default:
+		return fmt.Errorf("format %q is not a valid format", format)
+	}
+
+	if out != "" {
+		f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("opening output file: %+v", err)
+		}
+		defer f.Close()
+		next := emit
+		emit = func(msg *server.Message) error {
+			if err := writeLine(f, msg); err != nil {
+				return err
+			}
+			return next(msg)
+		}
+	}
+
+	config := server.Config{
+		ErrorHandler: func(err error) { fmt.Fprintf(os.Stderr, "gelfd: %+v\n", err) },
+	}
+	listeners := []struct {
+		addr   string
+		listen func(string, server.Config) (*server.Server, error)
+	}{
+		{udpAddr, server.ListenUDP},
+		{tcpAddr, server.ListenTCP},
+		{httpAddr, server.ListenHTTP},
+	}
+
+	var servers []*server.Server
+	defer func() {
+		for _, s := range servers {
+			s.Close()
+		}
+	}()
+	for _, l := range listeners {
+		if l.addr == "" {
+			continue
+		}
+		s, err := l.listen(l.addr, config)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "gelfd: listening on %s %s\n", s.Addr().Network(), s.Addr())
+		servers = append(servers, s)
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("no addresses to listen on")
+	}
+
+	messages := merge(servers)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	for {
+		select {
+		case msg := <-messages:
+			if err := emit(msg); err != nil {
+				return fmt.Errorf("writing message: %+v", err)
+			}
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+// merge delivers the Messages of all servers on a single channel.
+func merge(servers []*server.Server) <-chan *server.Message {
+	messages := make(chan *server.Message)
+	for _, s := range servers {
+		go func(s *server.Server) {
+			for msg := range s.Messages() {
+				messages <- msg
+			}
+		}(s)
+	}
+	return messages
+}
+
+func writeLine(w io.Writer, msg *server.Message) error {
+	line := make([]byte, 0, len(msg.Raw)+1)
+	_, err := w.Write(append(append(line, msg.Raw...), '\n'))
+	return err
+}
+
+// messageData maps a Message back to the log Data a graylog.Encoder would
+// have been given, so that it can be rendered by the console Encoder.
+func messageData(msg *server.Message) log.Data {
+	data := make(log.Data, len(msg.Fields)+4)
+	for k, v := range msg.Fields {
+		data[k] = v
+	}
+	if msg.Host != "" {
+		data["host"] = msg.Host
+	}
+	if msg.Timestamp != 0 {
+		data[log.TimestampKey] = msg.Time().Format(log.DefaultTimestampFormat)
+	}
+	data[log.LevelKey] = level(msg.Level)
+	data[log.MessageKey] = msg.ShortMessage
+	if msg.FullMessage != "" {
+		data[log.StackKey] = msg.FullMessage
+	}
+	return data
+}
+
+// level maps a syslog severity back to the Level graylog.SyslogLevel would
+// have mapped to it.
+func level(severity int) log.Level {
+	switch {
+	case severity <= 2:
+		return log.FatalLevel
+	case severity == 3:
+		return log.ErrorLevel
+	case severity <= 6:
+		return log.InfoLevel
+	}
+	return log.TraceLevel
+}
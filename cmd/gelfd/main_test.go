@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/graylog"
+	"github.com/PermissionData/log/graylog/server"
+)
+
+func TestLevel(t *testing.T) {
+	for _, lvl := range []log.Level{log.FatalLevel, log.ErrorLevel, log.InfoLevel, log.TraceLevel} {
+		if got := level(graylog.SyslogLevel(lvl)); got != lvl {
+			t.Errorf("level(SyslogLevel(%s)) returned %s", lvl, got)
+		}
+	}
+}
+
+func TestMessageData(t *testing.T) {
+	testCases := []struct {
+		name string
+		msg  *server.Message
+		want log.Data
+	}{
+		{
+			name: "minimal",
+			msg:  &server.Message{ShortMessage: "hi", Level: 6},
+			want: log.Data{log.LevelKey: log.InfoLevel, log.MessageKey: "hi"},
+		},
+		{
+			name: "full",
+			msg: &server.Message{
+				Host:         "web-01",
+				ShortMessage: "failed",
+				FullMessage:  "stack",
+				Timestamp:    1483326245.5,
+				Level:        3,
+				Fields:       map[string]interface{}{"user": "jdoe"},
+			},
+			want: log.Data{
+				"host":           "web-01",
+				"user":           "jdoe",
+				log.TimestampKey: "2017-01-02T03:04:05.500Z",
+				log.LevelKey:     log.ErrorLevel,
+				log.MessageKey:   "failed",
+				log.StackKey:     "stack",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := messageData(tc.msg); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("messageData returned %v, expected %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+)
+
+// Predicate reports whether a conditional Filter should be applied to an
+// entry.
+type Predicate func(lvl, threshold Level, data Data) bool
+
+// Chain provides a Filter that applies each of the filters in order, stopping
+// once the Data is nil, just as a Logger does.
+func Chain(filters ...Filter) Filter {
+	return func(lvl, threshold Level, data Data) Data {
+		for _, fn := range filters {
+			if data = fn(lvl, threshold, data); data == nil {
+				return nil
+			}
+		}
+		return data
+	}
+}
+
+// When provides a Filter that applies fn only to entries matching pred. Other
+// entries are passed on unchanged.
+func When(pred Predicate, fn Filter) Filter {
+	return Branch(pred, fn, nil)
+}
+
+// Branch provides a Filter that applies then to entries matching pred and
+// otherwise to the rest. A nil Filter passes entries on unchanged.
+func Branch(pred Predicate, then, otherwise Filter) Filter {
+	return func(lvl, threshold Level, data Data) Data {
+		if data == nil {
+			return nil
+		}
+		fn := otherwise
+		if pred(lvl, threshold, data) {
+			fn = then
+		}
+		if fn == nil {
+			return data
+		}
+		return fn(lvl, threshold, data)
+	}
+}
+
+// LevelIn provides a Predicate matching entries logged at any of the levels.
+func LevelIn(levels ...Level) Predicate {
+	return func(lvl, threshold Level, data Data) bool {
+		for _, l := range levels {
+			if lvl == l {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasField provides a Predicate matching entries with a value for key.
+func HasField(key string) Predicate {
+	return func(lvl, threshold Level, data Data) bool {
+		_, ok := data[key]
+		return ok
+	}
+}
+
+// FieldEquals provides a Predicate matching entries where the value for key is
+// equal to value.
+func FieldEquals(key string, value interface{}) Predicate {
+	return func(lvl, threshold Level, data Data) bool {
+		v, ok := data[key]
+		return ok && reflect.DeepEqual(v, value)
+	}
+}
+
+// Not provides a Predicate matching entries that pred does not.
+func Not(pred Predicate) Predicate {
+	return func(lvl, threshold Level, data Data) bool {
+		return !pred(lvl, threshold, data)
+	}
+}
+
+// PanicError describes a panic recovered while logging.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic while logging: %v", e.Value)
+}
+
+// Recover provides a Filter that recovers from any panic in fn, reports it as
+// a *PanicError, and passes the Data on as fn left it. Panics are reported to
+// DefaultErrorHandler when report is nil.
+func Recover(fn Filter, report func(error)) Filter {
+	return func(lvl, threshold Level, data Data) (out Data) {
+		defer func() {
+			if r := recover(); r != nil {
+				err := &PanicError{Value: r, Stack: debug.Stack()}
+				if report != nil {
+					report(err)
+				} else {
+					DefaultErrorHandler(err)
+				}
+				out = data
+			}
+		}()
+		return fn(lvl, threshold, data)
+	}
+}
@@ -0,0 +1,204 @@
+package log_test
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/PermissionData/log"
+)
+
+func TestChain(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filters  []log.Filter
+		inData   log.Data
+		wantData log.Data
+	}{
+		{"no filters",
+			nil,
+			log.Data{},
+			log.Data{},
+		},
+		{"nil data",
+			[]log.Filter{Pi},
+			nil,
+			nil,
+		},
+		{"multiple filters",
+			[]log.Filter{Pi, Phi},
+			log.Data{},
+			log.Data{"pi": 3.14, "phi": 1.618},
+		},
+		{"stops at nil",
+			[]log.Filter{
+				Pi,
+				func(lvl, threshold log.Level, data log.Data) log.Data { return nil },
+				func(lvl, threshold log.Level, data log.Data) log.Data {
+					t.Errorf("filter after nil Data should not be called")
+					return data
+				},
+			},
+			log.Data{},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			gotData := log.Chain(tc.filters...)(log.InfoLevel, log.InfoLevel, tc.inData)
+			if !reflect.DeepEqual(gotData, tc.wantData) {
+				t.Fatalf("Chain(...)(InfoLevel, InfoLevel, %+v) = %+v, expected %+v", tc.inData, gotData, tc.wantData)
+			}
+		})
+	}
+}
+
+func TestWhen(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pred     log.Predicate
+		lvl      log.Level
+		inData   log.Data
+		wantData log.Data
+	}{
+		{"nil data",
+			log.HasField("component"),
+			log.InfoLevel,
+			nil,
+			nil,
+		},
+		{"level matches",
+			log.LevelIn(log.FatalLevel, log.ErrorLevel),
+			log.ErrorLevel,
+			log.Data{},
+			log.Data{"pi": 3.14},
+		},
+		{"level does not match",
+			log.LevelIn(log.FatalLevel, log.ErrorLevel),
+			log.InfoLevel,
+			log.Data{},
+			log.Data{},
+		},
+		{"field equals",
+			log.FieldEquals("component", "db"),
+			log.InfoLevel,
+			log.Data{"component": "db"},
+			log.Data{"component": "db", "pi": 3.14},
+		},
+		{"field differs",
+			log.FieldEquals("component", "db"),
+			log.InfoLevel,
+			log.Data{"component": "http"},
+			log.Data{"component": "http"},
+		},
+		{"field missing",
+			log.FieldEquals("component", "db"),
+			log.InfoLevel,
+			log.Data{},
+			log.Data{},
+		},
+		{"field not comparable",
+			log.FieldEquals("tags", []string{"a"}),
+			log.InfoLevel,
+			log.Data{"tags": []string{"a"}},
+			log.Data{"tags": []string{"a"}, "pi": 3.14},
+		},
+		{"has field",
+			log.HasField("component"),
+			log.InfoLevel,
+			log.Data{"component": nil},
+			log.Data{"component": nil, "pi": 3.14},
+		},
+		{"not",
+			log.Not(log.HasField("component")),
+			log.InfoLevel,
+			log.Data{"component": nil},
+			log.Data{"component": nil},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			gotData := log.When(tc.pred, Pi)(tc.lvl, log.InfoLevel, tc.inData)
+			if !reflect.DeepEqual(gotData, tc.wantData) {
+				t.Fatalf("When(...)(%v, InfoLevel, %+v) = %+v, expected %+v", tc.lvl, tc.inData, gotData, tc.wantData)
+			}
+		})
+	}
+}
+
+func TestBranch(t *testing.T) {
+	filter := log.Branch(log.FieldEquals("component", "db"), Pi, Phi)
+
+	gotData := filter(log.InfoLevel, log.InfoLevel, log.Data{"component": "db"})
+	if want := (log.Data{"component": "db", "pi": 3.14}); !reflect.DeepEqual(gotData, want) {
+		t.Errorf("Branch(...) matching = %+v, expected %+v", gotData, want)
+	}
+
+	gotData = filter(log.InfoLevel, log.InfoLevel, log.Data{"component": "http"})
+	if want := (log.Data{"component": "http", "phi": 1.618}); !reflect.DeepEqual(gotData, want) {
+		t.Errorf("Branch(...) not matching = %+v, expected %+v", gotData, want)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	var reported []error
+	report := func(err error) { reported = append(reported, err) }
+
+	panics := func(lvl, threshold log.Level, data log.Data) log.Data {
+		data["before"] = true
+		panic("oops")
+	}
+
+	gotData := log.Recover(panics, report)(log.InfoLevel, log.InfoLevel, log.Data{"pi": 3.14})
+	if want := (log.Data{"pi": 3.14, "before": true}); !reflect.DeepEqual(gotData, want) {
+		t.Errorf("Recover(<panics>)(...) = %+v, expected %+v", gotData, want)
+	}
+	if len(reported) != 1 {
+		t.Fatalf("Recover(<panics>) reported %d errors, expected 1", len(reported))
+	}
+	perr, ok := reported[0].(*log.PanicError)
+	if !ok {
+		t.Fatalf("Recover(<panics>) reported %T, expected *log.PanicError", reported[0])
+	}
+	if perr.Value != "oops" || len(perr.Stack) == 0 {
+		t.Errorf("Recover(<panics>) reported %+v, expected the panic value and a stack", perr)
+	}
+
+	reported = nil
+	gotData = log.Recover(Pi, report)(log.InfoLevel, log.InfoLevel, log.Data{})
+	if want := (log.Data{"pi": 3.14}); !reflect.DeepEqual(gotData, want) {
+		t.Errorf("Recover(Pi)(...) = %+v, expected %+v", gotData, want)
+	}
+	if len(reported) != 0 {
+		t.Errorf("Recover(Pi) reported %v, expected nothing", reported)
+	}
+}
+
+func TestRecoverConcurrent(t *testing.T) {
+	defaultErrorHandler := log.DefaultErrorHandler
+	defer func() { log.DefaultErrorHandler = defaultErrorHandler }()
+	var reported int32
+	log.DefaultErrorHandler = func(error) { atomic.AddInt32(&reported, 1) }
+
+	filter := log.Recover(func(lvl, threshold log.Level, data log.Data) log.Data {
+		panic("oops")
+	}, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			filter(log.InfoLevel, log.InfoLevel, log.Data{})
+		}()
+	}
+	wg.Wait()
+	if reported != 8 {
+		t.Errorf("Recover(<panics>, nil) reported %d errors to DefaultErrorHandler, expected 8", reported)
+	}
+}
@@ -0,0 +1,292 @@
+// Package console provides an Encoder that renders log Data for people
+// reading logs in a terminal during local development.
+package console
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/internal/logdata"
+	"github.com/PermissionData/log/logfmt"
+)
+
+var _ log.Encoder = &Encoder{}
+
+// Part is a section of a rendered entry.
+type Part int
+
+const (
+	// TimestampPart renders the log.TimestampKey value.
+	TimestampPart Part = iota
+	// LevelPart renders the log.LevelKey value, colored by severity.
+	LevelPart
+	// MessagePart renders the log.MessageKey value.
+	MessagePart
+	// FieldsPart renders all remaining fields as aligned key=value pairs.
+	FieldsPart
+)
+
+// Layout describes how an Encoder renders each entry.
+type Layout struct {
+	// Parts are rendered in order, separated by spaces. Empty parts are
+	// skipped.
+	Parts []Part
+	// TimestampFormat is used to reformat timestamps written with
+	// log.DefaultTimestampFormat. An empty format leaves them as they are.
+	TimestampFormat string
+	// MessageWidth pads messages so that the fields following them line up.
+	MessageWidth int
+	// Hidden lists keys that are never rendered as fields.
+	Hidden []string
+}
+
+// DefaultLayout is used by a new Encoder.
+var DefaultLayout = Layout{
+	Parts:           []Part{TimestampPart, LevelPart, MessagePart, FieldsPart},
+	TimestampFormat: "15:04:05.000",
+	MessageWidth:    40,
+	Hidden:          []string{log.VersionKey},
+}
+
+// Encoder writes log Data to an output stream for people to read. Stack
+// traces added by log.StackFilter are written on the lines following the
+// entry.
+type Encoder struct {
+	w      io.Writer
+	layout Layout
+	color  bool
+
+	mux sync.Mutex
+	buf []byte
+}
+
+// NewEncoder returns a new Encoder that writes to w using the DefaultLayout.
+// Colors are enabled when w is a terminal and the NO_COLOR environment
+// variable is not set.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w:      w,
+		layout: DefaultLayout,
+		color:  colorSupported(w),
+	}
+}
+
+// SetColor overrides whether levels and keys are colored.
+func (enc *Encoder) SetColor(color bool) {
+	enc.color = color
+}
+
+// SetLayout sets how entries are rendered.
+func (enc *Encoder) SetLayout(layout Layout) {
+	enc.layout = layout
+}
+
+// Encode writes a rendering of v, which must be log.Data or a
+// map[string]interface{}.
+func (enc *Encoder) Encode(v interface{}) error {
+	data, err := logdata.Assert("console", v)
+	if err != nil {
+		return err
+	}
+
+	enc.mux.Lock()
+	defer enc.mux.Unlock()
+	b := enc.buf[:0]
+	fields := enc.fields(data)
+	for _, part := range enc.layout.Parts {
+		start := len(b)
+		if start > 0 {
+			b = append(b, ' ')
+		}
+		n := len(b)
+		switch part {
+		case TimestampPart:
+			b = enc.appendTimestamp(b, data)
+		case LevelPart:
+			b = enc.appendLevel(b, data)
+		case MessagePart:
+			b = enc.appendMessage(b, data, len(fields) > 0)
+		case FieldsPart:
+			b = enc.appendFields(b, data, fields)
+		}
+		if len(b) == n {
+			b = b[:start]
+		}
+	}
+	b = append(b, '\n')
+	b = enc.appendStack(b, data)
+	enc.buf = b
+
+	_, err = enc.w.Write(b)
+	return err
+}
+
+// fields lists the keys rendered by the FieldsPart.
+func (enc *Encoder) fields(data log.Data) []string {
+	skip := map[string]bool{log.StackKey: true}
+	for _, k := range enc.layout.Hidden {
+		skip[k] = true
+	}
+	for _, part := range enc.layout.Parts {
+		switch part {
+		case TimestampPart:
+			skip[log.TimestampKey] = true
+		case LevelPart:
+			skip[log.LevelKey] = true
+		case MessagePart:
+			skip[log.MessageKey] = true
+		}
+	}
+	var keys []string
+	for _, k := range log.SortKeys(data) {
+		if !skip[k] {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (enc *Encoder) appendTimestamp(b []byte, data log.Data) []byte {
+	switch ts := data[log.TimestampKey].(type) {
+	case time.Time:
+		format := enc.layout.TimestampFormat
+		if format == "" {
+			format = log.DefaultTimestampFormat
+		}
+		return enc.faint(b, ts.Format(format))
+	case string:
+		if t, err := time.Parse(log.DefaultTimestampFormat, ts); err == nil && enc.layout.TimestampFormat != "" {
+			ts = t.Format(enc.layout.TimestampFormat)
+		}
+		return enc.faint(b, ts)
+	case nil:
+		return b
+	default:
+		return enc.faint(b, fmt.Sprint(ts))
+	}
+}
+
+func (enc *Encoder) appendLevel(b []byte, data log.Data) []byte {
+	var lvl log.Level
+	switch val := data[log.LevelKey].(type) {
+	case log.Level:
+		lvl = val
+	case string:
+		if err := lvl.UnmarshalText([]byte(val)); err != nil {
+			return append(b, fmt.Sprintf("%-5s", strings.ToUpper(val))...)
+		}
+	case nil:
+		return b
+	default:
+		return append(b, fmt.Sprintf("%-5v", val)...)
+	}
+
+	txt := fmt.Sprintf("%-5s", strings.ToUpper(lvl.String()))
+	if !enc.color {
+		return append(b, txt...)
+	}
+	return append(append(append(b, levelColor(lvl)...), txt...), colorReset...)
+}
+
+func (enc *Encoder) appendMessage(b []byte, data log.Data, pad bool) []byte {
+	msg, ok := data[log.MessageKey]
+	if !ok {
+		return b
+	}
+	txt, ok := msg.(string)
+	if !ok {
+		txt = fmt.Sprint(msg)
+	}
+	b = append(b, txt...)
+	if pad {
+		for i := len([]rune(txt)); i < enc.layout.MessageWidth; i++ {
+			b = append(b, ' ')
+		}
+	}
+	return b
+}
+
+func (enc *Encoder) appendFields(b []byte, data log.Data, keys []string) []byte {
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		if enc.color {
+			b = append(b, colorFaint...)
+		}
+		b = logfmt.AppendKey(b, k)
+		b = append(b, '=')
+		if enc.color {
+			b = append(b, colorReset...)
+		}
+		b = logfmt.AppendValue(b, data[k])
+	}
+	return b
+}
+
+func (enc *Encoder) appendStack(b []byte, data log.Data) []byte {
+	stack, ok := data[log.StackKey]
+	if !ok || stack == nil {
+		return b
+	}
+	txt, ok := stack.(string)
+	if !ok {
+		txt = fmt.Sprint(stack)
+	}
+	for _, line := range strings.Split(strings.TrimRight(txt, "\n"), "\n") {
+		b = append(b, "    "...)
+		b = enc.faint(b, line)
+		b = append(b, '\n')
+	}
+	return b
+}
+
+func (enc *Encoder) faint(b []byte, s string) []byte {
+	if !enc.color {
+		return append(b, s...)
+	}
+	return append(append(append(b, colorFaint...), s...), colorReset...)
+}
+
+const (
+	colorReset   = "\x1b[0m"
+	colorFaint   = "\x1b[2m"
+	colorBoldRed = "\x1b[1;31m"
+	colorRed     = "\x1b[31m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+	colorMagenta = "\x1b[35m"
+)
+
+func levelColor(lvl log.Level) string {
+	switch lvl {
+	case log.FatalLevel:
+		return colorBoldRed
+	case log.ErrorLevel:
+		return colorRed
+	case log.InfoLevel:
+		return colorCyan
+	case log.TraceLevel:
+		return colorGray
+	}
+	return colorMagenta
+}
+
+// colorSupported reports whether w is a terminal and colors have not been
+// disabled with NO_COLOR.
+func colorSupported(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
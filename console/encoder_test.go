@@ -0,0 +1,155 @@
+package console_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/console"
+)
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name   string
+		layout *console.Layout
+		color  bool
+		input  interface{}
+		want   string
+	}{
+		{"default layout",
+			nil,
+			false,
+			log.Data{
+				log.TimestampKey: "2017-01-02T03:04:05.678Z",
+				log.VersionKey:   "1",
+				log.LevelKey:     log.InfoLevel,
+				log.MessageKey:   "user logged in",
+				"user":           "jdoe",
+				"pi":             3.14,
+			},
+			"03:04:05.678 INFO  user logged in                           pi=3.14 user=jdoe\n",
+		},
+		{"no fields",
+			nil,
+			false,
+			log.Data{
+				log.TimestampKey: time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC),
+				log.LevelKey:     "error",
+				log.MessageKey:   "failed",
+			},
+			"03:04:05.000 ERROR failed\n",
+		},
+		{"missing parts",
+			nil,
+			false,
+			map[string]interface{}{"pi": 3.14},
+			"pi=3.14\n",
+		},
+		{"stack",
+			nil,
+			false,
+			log.Data{
+				log.LevelKey:   log.FatalLevel,
+				log.MessageKey: "crashed",
+				log.StackKey:   "goroutine 1 [running]:\nmain.main()\n",
+			},
+			"FATAL crashed\n    goroutine 1 [running]:\n    main.main()\n",
+		},
+		{"colors",
+			nil,
+			true,
+			log.Data{
+				log.TimestampKey: "2017-01-02T03:04:05.678Z",
+				log.LevelKey:     log.ErrorLevel,
+				log.MessageKey:   "failed",
+				"pi":             3.14,
+			},
+			"\x1b[2m03:04:05.678\x1b[0m \x1b[31mERROR\x1b[0m failed                                   \x1b[2mpi=\x1b[0m3.14\n",
+		},
+		{"custom layout",
+			&console.Layout{
+				Parts:        []console.Part{console.LevelPart, console.FieldsPart},
+				MessageWidth: 10,
+			},
+			false,
+			log.Data{
+				log.TimestampKey: "2017-01-02T03:04:05.678Z",
+				log.VersionKey:   "1",
+				log.LevelKey:     log.TraceLevel,
+				log.MessageKey:   "hi",
+			},
+			"TRACE @timestamp=2017-01-02T03:04:05.678Z @version=1 message=hi\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := console.NewEncoder(&buf)
+			enc.SetColor(tc.color)
+			if tc.layout != nil {
+				enc.SetLayout(*tc.layout)
+			}
+			if err := enc.Encode(tc.input); err != nil {
+				t.Fatalf("Encode(%+v) returned unexpected error: %+v", tc.input, err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("Encode(%+v) wrote\n%q\nexpected\n%q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodeCycle(t *testing.T) {
+	data := log.Data{log.MessageKey: "hi"}
+	data["self"] = data
+
+	var buf bytes.Buffer
+	enc := console.NewEncoder(&buf)
+	enc.SetColor(false)
+	if err := enc.Encode(data); err != nil {
+		t.Fatalf("Encode(<cycle>) returned unexpected error: %+v", err)
+	}
+	if got := buf.String(); !strings.Contains(got, `self="json: unsupported value: `) {
+		t.Errorf("Encode(<cycle>) wrote %q, expected the error for self", got)
+	}
+}
+
+func TestEncodeRejectsOtherTypes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := console.NewEncoder(&buf).Encode(42); err == nil {
+		t.Fatalf("Encode(42) did not return an error")
+	}
+}
+
+func TestNewEncoderDisablesColor(t *testing.T) {
+	f, err := ioutil.TempFile("", "console")
+	if err != nil {
+		t.Fatalf("creating temp file: %+v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var buf bytes.Buffer
+	for _, w := range []io.Writer{&buf, f} {
+		if err := console.NewEncoder(w).Encode(log.Data{log.LevelKey: log.ErrorLevel}); err != nil {
+			t.Fatalf("Encode returned unexpected error: %+v", err)
+		}
+	}
+
+	written, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("reading temp file: %+v", err)
+	}
+	for _, b := range [][]byte{buf.Bytes(), written} {
+		if string(b) != "ERROR\n" {
+			t.Errorf("NewEncoder wrote %q to a non-terminal, expected no colors", b)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package console_test
+
+import (
+	"os"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/console"
+)
+
+func ExampleNewEncoder() {
+	enc := console.NewEncoder(os.Stdout)
+	enc.SetColor(false) // just for the example output
+
+	logger := log.New(log.Config{
+		Threshold: log.TraceLevel,
+		Encoder:   enc,
+		Filters: []log.Filter{
+			log.DefaultFilter,
+			func(lvl, threshold log.Level, data log.Data) log.Data {
+				// just for the example output
+				if data == nil {
+					return nil
+				}
+				data["@timestamp"] = "2017-01-02T03:04:05.678Z"
+				return data
+			},
+		},
+	})
+
+	logger.Log(log.InfoLevel, log.Data{
+		"message": "user logged in",
+		"user":    "jdoe",
+	})
+	// Output:
+	// 03:04:05.678 INFO  user logged in                           user=jdoe
+}
@@ -0,0 +1,219 @@
+// Package ecs maps log Data to the Elastic Common Schema, so that entries can
+// be used by Kibana dashboards and other tools that expect ECS field names.
+package ecs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/fastjson"
+	"github.com/PermissionData/log/internal/logdata"
+)
+
+// Version is the version of the Elastic Common Schema the mapping targets.
+const Version = "8.11.0"
+
+// DefaultFields maps keys commonly used in log Data to ECS field names.
+var DefaultFields = map[string]string{
+	log.MessageKey:   "message",
+	log.StackKey:     "error.stack_trace",
+	"file":           "log.origin.file.name",
+	"line":           "log.origin.file.line",
+	"func":           "log.origin.function",
+	"function":       "log.origin.function",
+	"logger":         "log.logger",
+	"method":         "http.request.method",
+	"http_method":    "http.request.method",
+	"status":         "http.response.status_code",
+	"status_code":    "http.response.status_code",
+	"bytes":          "http.response.body.bytes",
+	"response_bytes": "http.response.body.bytes",
+	"request_bytes":  "http.request.body.bytes",
+	"referer":        "http.request.referrer",
+	"referrer":       "http.request.referrer",
+	"request_id":     "http.request.id",
+	"url":            "url.full",
+	"path":           "url.path",
+	"query":          "url.query",
+	"user_agent":     "user_agent.original",
+	"remote_addr":    "client.address",
+	"client_ip":      "client.ip",
+	"duration":       "event.duration",
+	"trace_id":       "trace.id",
+	"span_id":        "span.id",
+}
+
+// numericFields are the ECS fields that must be numbers. Keys mapped to them
+// with values of other types are kept as they are.
+var numericFields = map[string]bool{
+	"log.origin.file.line":      true,
+	"http.response.status_code": true,
+	"http.response.body.bytes":  true,
+	"http.request.body.bytes":   true,
+	"event.duration":            true,
+}
+
+// DefaultErrorKeys are the keys holding errors, as passed to log.ErrorFilter.
+var DefaultErrorKeys = []string{"error", "err"}
+
+// CallerKey holds the location of the caller as "file:line".
+const CallerKey = "caller"
+
+// Config controls how Data is mapped to ECS.
+type Config struct {
+	// Fields maps keys to ECS field names. The default is DefaultFields.
+	Fields map[string]string
+	// ErrorKeys lists the keys holding errors, which are mapped to
+	// error.message and error.type. The default is DefaultErrorKeys.
+	ErrorKeys []string
+}
+
+// Filter provides a Filter that maps log Data to ECS. It should come after
+// any Filters that add the fields it maps, such as log.BaseFilter,
+// log.StackFilter, and log.ErrorFilter.
+func Filter(c Config) log.Filter {
+	return func(lvl, threshold log.Level, data log.Data) log.Data {
+		if data == nil {
+			return nil
+		}
+		return Map(data, c)
+	}
+}
+
+// Map converts data to a new Data using ECS field names, nested as ECS
+// expects. Keys without an ECS equivalent, or that ECS expects a number for
+// and hold something else, are kept as they are.
+func Map(data log.Data, c Config) log.Data {
+	if c.Fields == nil {
+		c.Fields = DefaultFields
+	}
+	if c.ErrorKeys == nil {
+		c.ErrorKeys = DefaultErrorKeys
+	}
+
+	flat := make(log.Data, len(data)+1)
+	flat["ecs.version"] = Version
+	for k, v := range data {
+		switch k {
+		case log.TimestampKey:
+			if t, ok := v.(time.Time); ok {
+				v = t.UTC().Format(log.DefaultTimestampFormat)
+			}
+			flat[k] = v
+			continue
+		case log.VersionKey:
+			continue
+		case log.LevelKey:
+			flat["log.level"] = strings.ToLower(fmt.Sprint(v))
+			continue
+		case CallerKey:
+			mapCaller(flat, v)
+			continue
+		}
+		if isErrorKey(k, c.ErrorKeys) {
+			mapError(flat, v)
+			continue
+		}
+		if name, ok := c.Fields[k]; ok {
+			if fv, ok := fieldValue(name, v); ok {
+				flat[name] = fv
+				continue
+			}
+		}
+		flat[k] = v
+	}
+	return log.Nest(flat, ".")
+}
+
+// fieldValue converts v for the ECS field name, reporting false when the field
+// must be a number and v is not one. Durations are only accepted for
+// event.duration, in nanoseconds.
+func fieldValue(name string, v interface{}) (interface{}, bool) {
+	if !numericFields[name] {
+		return v, true
+	}
+	switch val := v.(type) {
+	case time.Duration:
+		return val.Nanoseconds(), name == "event.duration"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, json.Number:
+		return v, true
+	}
+	return v, false
+}
+
+func isErrorKey(k string, keys []string) bool {
+	for _, key := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func mapError(flat log.Data, v interface{}) {
+	switch err := v.(type) {
+	case nil:
+	case error:
+		flat["error.message"] = err.Error()
+		flat["error.type"] = fmt.Sprintf("%T", err)
+	default:
+		flat["error.message"] = fmt.Sprint(err)
+	}
+}
+
+func mapCaller(flat log.Data, v interface{}) {
+	s, ok := v.(string)
+	if !ok {
+		flat[CallerKey] = v
+		return
+	}
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		flat["log.origin.file.name"] = s
+		return
+	}
+	line, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		flat["log.origin.file.name"] = s
+		return
+	}
+	flat["log.origin.file.name"] = s[:i]
+	flat["log.origin.file.line"] = line
+}
+
+var _ log.Encoder = &Encoder{}
+
+// Encoder writes log Data as ECS JSON documents, one per line, so that it can
+// replace the Encoder of an existing Logger without adding a Filter.
+type Encoder struct {
+	enc    *fastjson.Encoder
+	config Config
+}
+
+// NewEncoder returns an Encoder that writes to w using the default Config.
+func NewEncoder(w io.Writer) *Encoder {
+	enc := fastjson.NewEncoder(w)
+	enc.SetKeyOrder(log.TimestampKey, "log", log.MessageKey)
+	return &Encoder{enc: enc}
+}
+
+// SetConfig sets the Config used to map Data to ECS.
+func (enc *Encoder) SetConfig(c Config) {
+	enc.config = c
+}
+
+// Encode writes the ECS encoding of v, which must be log.Data or a
+// map[string]interface{}.
+func (enc *Encoder) Encode(v interface{}) error {
+	data, err := logdata.Assert("ecs", v)
+	if err != nil {
+		return err
+	}
+	return enc.enc.Encode(Map(data, enc.config))
+}
@@ -0,0 +1,244 @@
+package ecs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/ecs"
+)
+
+// ecsFields lists the ECS fields the mapping may produce, with the JSON type
+// each must have once decoded.
+var ecsFields = map[string]string{
+	"@timestamp":                "string",
+	"message":                   "string",
+	"ecs.version":               "string",
+	"log.level":                 "string",
+	"log.logger":                "string",
+	"log.origin.file.name":      "string",
+	"log.origin.file.line":      "number",
+	"log.origin.function":       "string",
+	"error.message":             "string",
+	"error.type":                "string",
+	"error.stack_trace":         "string",
+	"http.request.method":       "string",
+	"http.request.id":           "string",
+	"http.request.referrer":     "string",
+	"http.request.body.bytes":   "number",
+	"http.response.status_code": "number",
+	"http.response.body.bytes":  "number",
+	"url.full":                  "string",
+	"url.path":                  "string",
+	"url.query":                 "string",
+	"user_agent.original":       "string",
+	"client.address":            "string",
+	"client.ip":                 "string",
+	"event.duration":            "number",
+	"trace.id":                  "string",
+	"span.id":                   "string",
+}
+
+func TestMapProducesECSFields(t *testing.T) {
+	data := log.Data{
+		log.TimestampKey: "2017-01-02T03:04:05.000Z",
+		log.VersionKey:   "1",
+		log.LevelKey:     log.ErrorLevel,
+		log.MessageKey:   "request failed",
+		log.StackKey:     "goroutine 1 [running]:",
+		"error":          errors.New("connection reset"),
+		"caller":         "server/handler.go:42",
+		"func":           "server.(*Handler).ServeHTTP",
+		"logger":         "server",
+		"method":         "GET",
+		"status":         502,
+		"bytes":          1024,
+		"request_bytes":  0,
+		"referer":        "https://example.com/",
+		"request_id":     "abc123",
+		"url":            "https://example.com/api?q=1",
+		"path":           "/api",
+		"query":          "q=1",
+		"user_agent":     "curl/7.64.1",
+		"remote_addr":    "10.0.0.1:54321",
+		"client_ip":      "10.0.0.1",
+		"duration":       1500 * time.Millisecond,
+		"trace_id":       "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":        "00f067aa0ba902b7",
+	}
+
+	var buf bytes.Buffer
+	if err := ecs.NewEncoder(&buf).Encode(data); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Encode wrote invalid JSON %s: %+v", buf.String(), err)
+	}
+
+	got := map[string]string{}
+	leaves("", doc, got)
+	var missing []string
+	for name, typ := range ecsFields {
+		gotTyp, ok := got[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		if gotTyp != typ {
+			t.Errorf("ECS field %s has type %s, expected %s", name, gotTyp, typ)
+		}
+		delete(got, name)
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("Encode did not produce ECS fields %v in %s", missing, buf.String())
+	}
+	if len(got) > 0 {
+		t.Errorf("Encode produced fields outside the ECS field set: %v", got)
+	}
+}
+
+// leaves records the dotted path and JSON type of every leaf value in v.
+func leaves(path string, v interface{}, out map[string]string) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			leaves(p, item, out)
+		}
+	case string:
+		out[path] = "string"
+	case float64:
+		out[path] = "number"
+	default:
+		out[path] = reflect.TypeOf(v).String()
+	}
+}
+
+func TestMap(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   ecs.Config
+		inData   log.Data
+		wantData log.Data
+	}{
+		{"level and version",
+			ecs.Config{},
+			log.Data{log.LevelKey: log.InfoLevel, log.VersionKey: "1"},
+			log.Data{
+				"ecs": log.Data{"version": ecs.Version},
+				"log": log.Data{"level": "info"},
+			},
+		},
+		{"time timestamp",
+			ecs.Config{},
+			log.Data{log.TimestampKey: time.Date(2017, 1, 2, 3, 4, 5, 0, time.FixedZone("X", 3600))},
+			log.Data{
+				"@timestamp": "2017-01-02T02:04:05.000Z",
+				"ecs":        log.Data{"version": ecs.Version},
+			},
+		},
+		{"string error from ErrorFilter",
+			ecs.Config{},
+			log.Data{"err": "oops"},
+			log.Data{
+				"ecs":   log.Data{"version": ecs.Version},
+				"error": log.Data{"message": "oops"},
+			},
+		},
+		{"caller without line",
+			ecs.Config{},
+			log.Data{"caller": "main.go"},
+			log.Data{
+				"ecs": log.Data{"version": ecs.Version},
+				"log": log.Data{"origin": log.Data{"file": log.Data{"name": "main.go"}}},
+			},
+		},
+		{"custom fields and error keys",
+			ecs.Config{
+				Fields:    map[string]string{"verb": "http.request.method"},
+				ErrorKeys: []string{"failure"},
+			},
+			log.Data{"verb": "POST", "method": "kept", "failure": errors.New("oops")},
+			log.Data{
+				"ecs":    log.Data{"version": ecs.Version},
+				"http":   log.Data{"request": log.Data{"method": "POST"}},
+				"method": "kept",
+				"error":  log.Data{"message": "oops", "type": "*errors.errorString"},
+			},
+		},
+		{"numeric fields",
+			ecs.Config{},
+			log.Data{
+				"status":        "OK",
+				"status_code":   json.Number("200"),
+				"bytes":         "1k",
+				"request_bytes": time.Second,
+				"duration":      1500 * time.Millisecond,
+			},
+			log.Data{
+				"ecs":           log.Data{"version": ecs.Version},
+				"http":          log.Data{"response": log.Data{"status_code": json.Number("200")}},
+				"event":         log.Data{"duration": int64(1500000000)},
+				"status":        "OK",
+				"bytes":         "1k",
+				"request_bytes": time.Second,
+			},
+		},
+		{"non-numeric duration kept",
+			ecs.Config{},
+			log.Data{"duration": "fast"},
+			log.Data{"ecs": log.Data{"version": ecs.Version}, "duration": "fast"},
+		},
+		{"unmapped fields kept",
+			ecs.Config{},
+			log.Data{"pi": 3.14},
+			log.Data{"ecs": log.Data{"version": ecs.Version}, "pi": 3.14},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			gotData := ecs.Filter(tc.config)(log.InfoLevel, log.InfoLevel, tc.inData)
+			if !reflect.DeepEqual(gotData, tc.wantData) {
+				t.Fatalf("Filter(%+v)(InfoLevel, InfoLevel, %+v) = %+v, expected %+v", tc.config, tc.inData, gotData, tc.wantData)
+			}
+		})
+	}
+
+	if data := ecs.Filter(ecs.Config{})(log.InfoLevel, log.InfoLevel, nil); data != nil {
+		t.Errorf("Filter returned %+v for nil data, expected nil", data)
+	}
+}
+
+func TestMapCopiesNestedData(t *testing.T) {
+	http := log.Data{"version": "1.1"}
+	got := ecs.Map(log.Data{"http": http, "method": "GET"}, ecs.Config{})
+
+	want := log.Data{"version": "1.1", "request": log.Data{"method": "GET"}}
+	if !reflect.DeepEqual(got["http"], want) {
+		t.Errorf("Map wrote http %+v, expected %+v", got["http"], want)
+	}
+	if !reflect.DeepEqual(http, log.Data{"version": "1.1"}) {
+		t.Errorf("Map modified the http Data to %+v", http)
+	}
+}
+
+func TestEncodeRejectsOtherTypes(t *testing.T) {
+	var buf bytes.Buffer
+	err := ecs.NewEncoder(&buf).Encode("message")
+	if err == nil || !strings.HasPrefix(err.Error(), "ecs:") {
+		t.Fatalf("Encode(string) returned %v, expected an ecs error", err)
+	}
+}
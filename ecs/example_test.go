@@ -0,0 +1,36 @@
+package ecs_test
+
+import (
+	"errors"
+	"os"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/ecs"
+)
+
+func ExampleNewEncoder() {
+	logger := log.New(log.Config{
+		Threshold: log.TraceLevel,
+		Encoder:   ecs.NewEncoder(os.Stdout),
+		Filters: []log.Filter{
+			log.DefaultFilter,
+			func(lvl, threshold log.Level, data log.Data) log.Data {
+				// just for the example output
+				if data == nil {
+					return nil
+				}
+				data["@timestamp"] = "2017-01-02T03:04:05.000Z"
+				return data
+			},
+		},
+	})
+
+	logger.Log(log.ErrorLevel, log.Data{
+		"message": "request failed",
+		"error":   errors.New("connection reset"),
+		"method":  "GET",
+		"status":  502,
+	})
+	// Output:
+	// {"@timestamp":"2017-01-02T03:04:05.000Z","log":{"level":"error"},"message":"request failed","ecs":{"version":"8.11.0"},"error":{"message":"connection reset","type":"*errors.errorString"},"http":{"request":{"method":"GET"},"response":{"status_code":502}}}
+}
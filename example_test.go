@@ -5,8 +5,10 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/fastjson"
 )
 
 func ExampleNew_customFilters() {
@@ -131,3 +133,28 @@ func ExampleWithLevels() {
 	// {"@timestamp":null,"@version":"1","foo":"bar","hey":{"Ho":true},"log_level":"Fatal","pi":3.14}
 	// {"@timestamp":null,"@version":"1","foo":"bar","hey":{"Ho":true},"log_level":"Fatal","pi":3.14}
 }
+
+func ExampleLogFields() {
+	logger := log.New(log.Config{
+		Threshold: log.TraceLevel,
+		Encoder:   fastjson.NewEncoder(os.Stdout),
+		FieldFilters: []log.FieldFilter{
+			log.DefaultFieldFilter,
+			func(lvl, threshold log.Level, fields []log.Field) []log.Field {
+				if fields == nil {
+					return nil
+				}
+				fields[0] = log.Any("@timestamp", nil)
+				return fields
+			},
+		},
+	})
+
+	log.LogFields(logger, log.InfoLevel,
+		log.String("foo", "bar"),
+		log.Float64("pi", 3.14),
+		log.Dur("took", 1500*time.Millisecond),
+	)
+	// Output:
+	// {"@timestamp":null,"@version":"1","log_level":"Info","foo":"bar","pi":3.14,"took":1500000000}
+}
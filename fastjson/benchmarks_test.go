@@ -0,0 +1,58 @@
+package fastjson_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/fastjson"
+)
+
+func BenchmarkEncode(b *testing.B) {
+	data := log.Data{
+		"@timestamp": time.Now().UTC().Format(log.DefaultTimestampFormat),
+		"@version":   "1",
+		"log_level":  log.InfoLevel,
+		"message":    "request completed",
+		"status":     200,
+		"duration":   0.0123,
+		"path":       "/api/v1/users?id=<1>",
+		"ok":         true,
+		"tags":       []interface{}{"a", "b", "c"},
+		"req":        log.Data{"method": "GET", "bytes": 512},
+	}
+
+	b.Run("encoding/json", func(b *testing.B) {
+		enc := json.NewEncoder(ioutil.Discard)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			enc.Encode(data)
+		}
+	})
+	b.Run("fastjson", func(b *testing.B) {
+		enc := fastjson.NewEncoder(ioutil.Discard)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			enc.Encode(data)
+		}
+	})
+	b.Run("fastjson ordered", func(b *testing.B) {
+		enc := fastjson.NewEncoder(ioutil.Discard)
+		enc.SetKeyOrder(log.DefaultKeyOrder...)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			enc.Encode(data)
+		}
+	})
+	b.Run("fastjson parallel", func(b *testing.B) {
+		enc := fastjson.NewEncoder(ioutil.Discard)
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				enc.Encode(data)
+			}
+		})
+	})
+}
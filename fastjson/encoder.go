@@ -0,0 +1,377 @@
+// Package fastjson provides a JSON Encoder specialized for log Data. Common
+// value types are written without reflection, and other values fall back to
+// encoding/json. Values are written as json.Encoder would write them, though
+// object keys are only sorted when a key order is set.
+package fastjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PermissionData/log"
+)
+
+var (
+	_ log.Encoder      = &Encoder{}
+	_ log.FieldEncoder = &Encoder{}
+)
+
+// Encoder writes JSON values to an output stream, each followed by a newline.
+// Unlike json.Encoder, map keys are written in no particular order unless a
+// key order is set.
+type Encoder struct {
+	w          io.Writer
+	escapeHTML bool
+	ordered    bool
+	keyOrder   []string
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, escapeHTML: true}
+}
+
+// SetEscapeHTML specifies whether problematic HTML characters should be
+// escaped inside JSON quoted strings, as with json.Encoder.
+func (enc *Encoder) SetEscapeHTML(on bool) {
+	enc.escapeHTML = on
+}
+
+// SetKeyOrder makes the order of keys deterministic, writing any of the keys
+// given first, in order, followed by the remaining keys sorted. Calling
+// SetKeyOrder with log.DefaultKeyOrder puts well-known keys first.
+func (enc *Encoder) SetKeyOrder(keys ...string) {
+	enc.ordered = true
+	enc.keyOrder = keys
+}
+
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+// maxPooledBuffer keeps unusually large entries from pinning memory in the
+// pool.
+const maxPooledBuffer = 64 << 10
+
+// Encode writes the JSON encoding of v followed by a newline.
+func (enc *Encoder) Encode(v interface{}) error {
+	bp := bufPool.Get().(*[]byte)
+	defer func() {
+		if cap(*bp) <= maxPooledBuffer {
+			bufPool.Put(bp)
+		}
+	}()
+
+	b, err := enc.appendValue((*bp)[:0], v, 0)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	*bp = b
+
+	_, err = enc.w.Write(b)
+	return err
+}
+
+// EncodeFields writes fields as a JSON object followed by a newline. Fields
+// are written in order unless a key order is set, so a repeated key is
+// written more than once, which most decoders resolve by keeping the last.
+func (enc *Encoder) EncodeFields(fields []log.Field) error {
+	if enc.ordered {
+		return enc.Encode(log.FieldsData(fields))
+	}
+
+	bp := bufPool.Get().(*[]byte)
+	defer func() {
+		if cap(*bp) <= maxPooledBuffer {
+			bufPool.Put(bp)
+		}
+	}()
+
+	b := append((*bp)[:0], '{')
+	var err error
+	for i, f := range fields {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendString(b, f.Key, enc.escapeHTML)
+		b = append(b, ':')
+		if b, err = enc.appendField(b, f); err != nil {
+			return err
+		}
+	}
+	b = append(b, '}', '\n')
+	*bp = b
+
+	_, err = enc.w.Write(b)
+	return err
+}
+
+func (enc *Encoder) appendField(b []byte, f log.Field) ([]byte, error) {
+	switch f.Type {
+	case log.StringType:
+		return appendString(b, f.String, enc.escapeHTML), nil
+	case log.IntType, log.DurationType:
+		return strconv.AppendInt(b, f.Integer, 10), nil
+	case log.FloatType:
+		return appendFloat(b, math.Float64frombits(uint64(f.Integer)), 64)
+	case log.BoolType:
+		return strconv.AppendBool(b, f.Integer == 1), nil
+	case log.ErrorType:
+		if err, ok := f.Interface.(error); ok && err != nil {
+			return appendString(b, err.Error(), enc.escapeHTML), nil
+		}
+		return append(b, "null"...), nil
+	case log.TimeType:
+		if _, ok := f.Interface.(time.Time); ok {
+			break
+		}
+		t := time.Unix(0, f.Integer)
+		if loc, ok := f.Interface.(*time.Location); ok {
+			t = t.In(loc)
+		}
+		if y := t.Year(); y < 0 || y >= 10000 {
+			break
+		}
+		b = append(b, '"')
+		b = t.AppendFormat(b, time.RFC3339Nano)
+		return append(b, '"'), nil
+	case log.AnyType:
+		return enc.appendValue(b, f.Interface, 0)
+	}
+	return enc.appendValue(b, f.Value(), 0)
+}
+
+// maxDepth bounds how deeply appendValue recurses before handing values to
+// encoding/json, which reports cycles as errors rather than overflowing the
+// stack.
+const maxDepth = 100
+
+func (enc *Encoder) appendValue(b []byte, v interface{}, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return enc.appendFallback(b, v)
+	}
+	switch val := v.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case string:
+		return appendString(b, val, enc.escapeHTML), nil
+	case bool:
+		return strconv.AppendBool(b, val), nil
+	case int:
+		return strconv.AppendInt(b, int64(val), 10), nil
+	case int8:
+		return strconv.AppendInt(b, int64(val), 10), nil
+	case int16:
+		return strconv.AppendInt(b, int64(val), 10), nil
+	case int32:
+		return strconv.AppendInt(b, int64(val), 10), nil
+	case int64:
+		return strconv.AppendInt(b, val, 10), nil
+	case uint:
+		return strconv.AppendUint(b, uint64(val), 10), nil
+	case uint8:
+		return strconv.AppendUint(b, uint64(val), 10), nil
+	case uint16:
+		return strconv.AppendUint(b, uint64(val), 10), nil
+	case uint32:
+		return strconv.AppendUint(b, uint64(val), 10), nil
+	case uint64:
+		return strconv.AppendUint(b, val, 10), nil
+	case float32:
+		return appendFloat(b, float64(val), 32)
+	case float64:
+		return appendFloat(b, val, 64)
+	case log.Level:
+		return appendString(b, val.String(), enc.escapeHTML), nil
+	case time.Time:
+		if y := val.Year(); y < 0 || y >= 10000 {
+			break // let encoding/json report the error
+		}
+		b = append(b, '"')
+		b = val.AppendFormat(b, time.RFC3339Nano)
+		return append(b, '"'), nil
+	case log.Data:
+		return enc.appendMap(b, val, depth)
+	case map[string]interface{}:
+		return enc.appendMap(b, val, depth)
+	case []interface{}:
+		if val == nil {
+			return append(b, "null"...), nil
+		}
+		b = append(b, '[')
+		var err error
+		for i, item := range val {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = enc.appendValue(b, item, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return append(b, ']'), nil
+	case []string:
+		if val == nil {
+			return append(b, "null"...), nil
+		}
+		b = append(b, '[')
+		for i, item := range val {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendString(b, item, enc.escapeHTML)
+		}
+		return append(b, ']'), nil
+	case log.Lazy:
+		if val == nil {
+			return append(b, "null"...), nil
+		}
+		return enc.appendValue(b, val(), depth+1)
+	}
+	return enc.appendFallback(b, v)
+}
+
+// appendFallback writes v using encoding/json, honoring the HTML escaping
+// setting of the Encoder.
+func (enc *Encoder) appendFallback(b []byte, v interface{}) ([]byte, error) {
+	if enc.escapeHTML {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return b, err
+		}
+		return append(b, raw...), nil
+	}
+	var buf bytes.Buffer
+	jenc := json.NewEncoder(&buf)
+	jenc.SetEscapeHTML(false)
+	if err := jenc.Encode(v); err != nil {
+		return b, err
+	}
+	return append(b, bytes.TrimSuffix(buf.Bytes(), []byte("\n"))...), nil
+}
+
+func (enc *Encoder) appendMap(b []byte, m map[string]interface{}, depth int) ([]byte, error) {
+	if m == nil {
+		return append(b, "null"...), nil
+	}
+	b = append(b, '{')
+	var err error
+	if !enc.ordered {
+		first := true
+		for k, v := range m {
+			if !first {
+				b = append(b, ',')
+			}
+			first = false
+			b = appendString(b, k, enc.escapeHTML)
+			b = append(b, ':')
+			if b, err = enc.appendValue(b, v, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return append(b, '}'), nil
+	}
+
+	keys := log.SortKeys(m, enc.keyOrder...)
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendString(b, k, enc.escapeHTML)
+		b = append(b, ':')
+		if b, err = enc.appendValue(b, m[k], depth+1); err != nil {
+			return b, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// appendFloat formats f as encoding/json does, like ES6 number to string
+// conversion.
+func appendFloat(b []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return b, fmt.Errorf("json: unsupported value: %s", strconv.FormatFloat(f, 'g', -1, bits))
+	}
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+const hex = "0123456789abcdef"
+
+// appendString writes s as a quoted JSON string, escaping it the same way
+// encoding/json does.
+func appendString(b []byte, s string, escapeHTML bool) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && (!escapeHTML || (c != '<' && c != '>' && c != '&')) {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '\\', '"':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
@@ -0,0 +1,256 @@
+package fastjson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/fastjson"
+)
+
+func TestEncodeMatchesEncodingJSON(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input interface{}
+	}{
+		{"nil", nil},
+		{"empty data", log.Data{}},
+		{"nil data", log.Data(nil)},
+		{"scalars", log.Data{
+			"string": "hello",
+			"bool":   true,
+			"int":    -42,
+			"int8":   int8(-8),
+			"int16":  int16(16),
+			"int32":  int32(32),
+			"int64":  int64(1) << 60,
+			"uint":   uint(7),
+			"uint8":  uint8(8),
+			"uint16": uint16(16),
+			"uint32": uint32(32),
+			"uint64": uint64(1) << 63,
+			"nil":    nil,
+		}},
+		{"floats", log.Data{
+			"pi":        3.14,
+			"zero":      0.0,
+			"negative":  -1.5,
+			"large":     1e21,
+			"small":     1e-7,
+			"tiny":      float32(1e-7),
+			"float32":   float32(3.14),
+			"integral":  100.0,
+			"precision": 0.1 + 0.2,
+		}},
+		{"strings needing escapes", log.Data{
+			"quote":     `say "hi"`,
+			"backslash": `a\b`,
+			"control":   "a\nb\tc\rd\be\ff\x01",
+			"html":      "<a href=\"x\">&</a>",
+			"unicode":   "héllo wörld ✓",
+			"invalid":   "a\xffb",
+			"separator": "a b c",
+			"<key>":     "keys are escaped too",
+		}},
+		{"well-known types", log.Data{
+			"level": log.InfoLevel,
+			"when":  time.Date(2017, 1, 2, 3, 4, 5, 600000000, time.FixedZone("X", 3600)),
+			"tags":  []string{"a", "b"},
+			"list":  []interface{}{1, "two", nil, log.Data{"x": 1}},
+			"map":   map[string]interface{}{"nested": log.Data{"deep": true}},
+			"lazy":  log.Lazy(func() interface{} { return 3.14 }),
+		}},
+		{"fallback types", log.Data{
+			"struct":  struct{ A, B int }{1, 2},
+			"ip":      net.IPv4(127, 0, 0, 1),
+			"ints":    []int{1, 2, 3},
+			"bytes":   []byte("raw"),
+			"error":   fmt.Errorf("oops"),
+			"ptr":     &struct{ C string }{"<c>"},
+			"typed":   map[string]int{"one": 1},
+			"number":  json.Number("12.50"),
+			"raw":     json.RawMessage(`{"a":1}`),
+			"nilList": []string(nil),
+		}},
+	}
+
+	for _, escapeHTML := range []bool{true, false} {
+		for _, tc := range testCases {
+			tc := tc
+			escapeHTML := escapeHTML
+			t.Run(fmt.Sprintf("%s escapeHTML=%v", tc.name, escapeHTML), func(t *testing.T) {
+				var want bytes.Buffer
+				jenc := json.NewEncoder(&want)
+				jenc.SetEscapeHTML(escapeHTML)
+				if err := jenc.Encode(tc.input); err != nil {
+					t.Fatalf("json.Encoder.Encode(%+v) returned unexpected error: %+v", tc.input, err)
+				}
+
+				var got bytes.Buffer
+				enc := fastjson.NewEncoder(&got)
+				enc.SetEscapeHTML(escapeHTML)
+				enc.SetKeyOrder()
+				if err := enc.Encode(tc.input); err != nil {
+					t.Fatalf("Encode(%+v) returned unexpected error: %+v", tc.input, err)
+				}
+				if got.String() != want.String() {
+					t.Errorf("Encode(%+v) wrote\n%s\nexpected\n%s", tc.input, got.String(), want.String())
+				}
+			})
+		}
+	}
+}
+
+func TestEncodeKeyOrder(t *testing.T) {
+	data := log.Data{
+		"zeta":           1,
+		"alpha":          2,
+		log.MessageKey:   "hello",
+		log.LevelKey:     log.InfoLevel,
+		log.TimestampKey: "2017-01-02T03:04:05.000Z",
+	}
+
+	var buf bytes.Buffer
+	enc := fastjson.NewEncoder(&buf)
+	enc.SetKeyOrder(log.DefaultKeyOrder...)
+	if err := enc.Encode(data); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	want := `{"@timestamp":"2017-01-02T03:04:05.000Z","log_level":"Info","message":"hello","alpha":2,"zeta":1}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Encode with key order wrote %s, expected %s", buf.String(), want)
+	}
+}
+
+func TestEncodeUnordered(t *testing.T) {
+	data := log.Data{"a": 1, "b": []interface{}{"c"}, "d": log.Data{"e": 2.5}}
+
+	var buf bytes.Buffer
+	if err := fastjson.NewEncoder(&buf).Encode(data); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Encode wrote invalid JSON %s: %+v", buf.String(), err)
+	}
+	if len(got) != 3 || got["a"] != 1.0 {
+		t.Errorf("Encode wrote %s, expected all of %+v", buf.String(), data)
+	}
+}
+
+func cyclic() log.Data {
+	data := log.Data{"a": 1}
+	data["self"] = data
+	return data
+}
+
+func TestEncodeDeep(t *testing.T) {
+	var deep interface{} = "bottom"
+	for i := 0; i < 200; i++ {
+		deep = log.Data{"next": deep, "list": []interface{}{i}}
+	}
+
+	var want, got bytes.Buffer
+	json.NewEncoder(&want).Encode(deep)
+	enc := fastjson.NewEncoder(&got)
+	enc.SetKeyOrder()
+	if err := enc.Encode(deep); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("Encode wrote\n%s\nexpected\n%s", got.String(), want.String())
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input interface{}
+	}{
+		{"NaN", log.Data{"nan": math.NaN()}},
+		{"infinity", log.Data{"inf": float32(math.Inf(1))}},
+		{"channel", log.Data{"ch": make(chan int)}},
+		{"nested", []interface{}{log.Data{"nan": math.NaN()}}},
+		{"cycle", cyclic()},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := fastjson.NewEncoder(&buf).Encode(tc.input); err == nil {
+				t.Fatalf("Encode(%+v) did not return an error", tc.input)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("Encode(%+v) wrote %q despite an error", tc.input, buf.String())
+			}
+		})
+	}
+}
+
+func TestEncodeFields(t *testing.T) {
+	when := time.Date(2017, 1, 2, 3, 4, 5, 600, time.FixedZone("X", 3600))
+	fields := []log.Field{
+		log.String("message", "<hello>"),
+		log.Int("n", -1),
+		log.Int64("big", 1<<60),
+		log.Float64("pi", 3.14),
+		log.Bool("ok", true),
+		log.Dur("took", time.Millisecond),
+		log.Time("when", when),
+		log.Time("zero", time.Time{}),
+		log.Err("error", fmt.Errorf("oops")),
+		log.Err("nil", nil),
+		log.Any("level", log.InfoLevel),
+		log.Any("list", []interface{}{1, "a"}),
+	}
+
+	for _, ordered := range []bool{false, true} {
+		var want, got bytes.Buffer
+		jenc := json.NewEncoder(&want)
+		if err := jenc.Encode(log.FieldsData(fields)); err != nil {
+			t.Fatalf("json.Encoder.Encode returned unexpected error: %+v", err)
+		}
+
+		enc := fastjson.NewEncoder(&got)
+		if ordered {
+			enc.SetKeyOrder()
+		}
+		if err := enc.EncodeFields(fields); err != nil {
+			t.Fatalf("EncodeFields returned unexpected error: %+v", err)
+		}
+
+		var gotData, wantData map[string]interface{}
+		if err := json.Unmarshal(got.Bytes(), &gotData); err != nil {
+			t.Fatalf("EncodeFields wrote invalid JSON %s: %+v", got.String(), err)
+		}
+		json.Unmarshal(want.Bytes(), &wantData)
+		if !reflect.DeepEqual(gotData, wantData) {
+			t.Errorf("EncodeFields (ordered=%v) wrote\n%s\nexpected the same as\n%s", ordered, got.String(), want.String())
+		}
+		if ordered && got.String() != want.String() {
+			t.Errorf("EncodeFields with key order wrote\n%s\nexpected\n%s", got.String(), want.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := fastjson.NewEncoder(&buf).EncodeFields([]log.Field{log.Float64("nan", math.NaN())}); err == nil || buf.Len() != 0 {
+		t.Errorf("EncodeFields with NaN wrote %q and returned %v, expected only an error", buf.String(), err)
+	}
+}
+
+func TestEncodeFieldsZeroTime(t *testing.T) {
+	var got bytes.Buffer
+	if err := fastjson.NewEncoder(&got).EncodeFields([]log.Field{log.Time("t", time.Time{})}); err != nil {
+		t.Fatalf("EncodeFields returned unexpected error: %+v", err)
+	}
+	if want := `{"t":"0001-01-01T00:00:00Z"}` + "\n"; got.String() != want {
+		t.Errorf("EncodeFields wrote %s, expected %s", got.String(), want)
+	}
+}
@@ -0,0 +1,187 @@
+package log
+
+import (
+	"math"
+	"time"
+)
+
+// FieldType identifies which member of a Field holds its value.
+type FieldType uint8
+
+// FieldTypes for each of the Field constructors.
+const (
+	AnyType FieldType = iota
+	StringType
+	IntType
+	FloatType
+	BoolType
+	DurationType
+	TimeType
+	ErrorType
+)
+
+// Field is a single typed key and value. Unlike values in Data, common types
+// are stored without boxing them in an interface, and entries built from
+// Fields need no map. Fields should be created with the constructors below
+// rather than directly.
+type Field struct {
+	Key       string
+	Type      FieldType
+	Integer   int64
+	String    string
+	Interface interface{}
+}
+
+// String constructs a Field holding a string.
+func String(key, val string) Field {
+	return Field{Key: key, Type: StringType, String: val}
+}
+
+// Int constructs a Field holding an int.
+func Int(key string, val int) Field {
+	return Field{Key: key, Type: IntType, Integer: int64(val)}
+}
+
+// Int64 constructs a Field holding an int64.
+func Int64(key string, val int64) Field {
+	return Field{Key: key, Type: IntType, Integer: val}
+}
+
+// Float64 constructs a Field holding a float64.
+func Float64(key string, val float64) Field {
+	return Field{Key: key, Type: FloatType, Integer: int64(math.Float64bits(val))}
+}
+
+// Bool constructs a Field holding a bool.
+func Bool(key string, val bool) Field {
+	f := Field{Key: key, Type: BoolType}
+	if val {
+		f.Integer = 1
+	}
+	return f
+}
+
+// Dur constructs a Field holding a time.Duration.
+func Dur(key string, val time.Duration) Field {
+	return Field{Key: key, Type: DurationType, Integer: int64(val)}
+}
+
+// Time constructs a Field holding a time.Time. The monotonic clock reading is
+// not kept.
+func Time(key string, val time.Time) Field {
+	if val.Before(minUnixNano) || val.After(maxUnixNano) {
+		// UnixNano is undefined here, as for the zero Time
+		return Field{Key: key, Type: TimeType, Interface: val.Round(0)}
+	}
+	return Field{Key: key, Type: TimeType, Integer: val.UnixNano(), Interface: val.Location()}
+}
+
+// minUnixNano and maxUnixNano bound the times UnixNano can represent.
+var (
+	minUnixNano = time.Unix(0, math.MinInt64)
+	maxUnixNano = time.Unix(0, math.MaxInt64)
+)
+
+// Err constructs a Field holding the message of err, or nil when err is nil.
+func Err(key string, err error) Field {
+	return Field{Key: key, Type: ErrorType, Interface: err}
+}
+
+// Any constructs a Field holding any value, which is encoded as it would be
+// in Data.
+func Any(key string, val interface{}) Field {
+	return Field{Key: key, Type: AnyType, Interface: val}
+}
+
+// Value returns the value of the Field as it would appear in Data.
+func (f Field) Value() interface{} {
+	switch f.Type {
+	case StringType:
+		return f.String
+	case IntType:
+		return f.Integer
+	case FloatType:
+		return math.Float64frombits(uint64(f.Integer))
+	case BoolType:
+		return f.Integer == 1
+	case DurationType:
+		return time.Duration(f.Integer)
+	case TimeType:
+		if t, ok := f.Interface.(time.Time); ok {
+			return t
+		}
+		t := time.Unix(0, f.Integer)
+		if loc, ok := f.Interface.(*time.Location); ok {
+			t = t.In(loc)
+		}
+		return t
+	case ErrorType:
+		if err, ok := f.Interface.(error); ok && err != nil {
+			return err.Error()
+		}
+		return nil
+	}
+	return f.Interface
+}
+
+// FieldsData converts fields to Data for Filters and Encoders that do not
+// support Fields. Later Fields replace earlier Fields with the same key.
+func FieldsData(fields []Field) Data {
+	data := make(Data, len(fields))
+	for _, f := range fields {
+		data[f.Key] = f.Value()
+	}
+	return data
+}
+
+// FieldLogger is implemented by Loggers that can log Fields without first
+// converting them to Data.
+type FieldLogger interface {
+	LogFields(Level, ...Field)
+}
+
+// LogFields logs fields to lg at lvl, converting them to Data when lg does not
+// implement FieldLogger.
+func LogFields(lg Logger, lvl Level, fields ...Field) {
+	if fl, ok := lg.(FieldLogger); ok {
+		fl.LogFields(lvl, fields...)
+		return
+	}
+	lg.Log(lvl, FieldsData(fields))
+}
+
+// FieldEncoder is implemented by Encoders that can write Fields directly.
+// Fields may repeat a key, in which case the last one should win.
+type FieldEncoder interface {
+	EncodeFields([]Field) error
+}
+
+// FieldFilter is the counterpart of Filter for entries logged as Fields. It
+// may modify or append to fields, and returning nil stops the entry from
+// being logged.
+type FieldFilter func(lvl, threshold Level, fields []Field) []Field
+
+// DefaultFieldFilter sets the BaseFieldFilter to be enabled by default.
+var DefaultFieldFilter = BaseFieldFilter()
+
+// BaseFieldFilter provides a FieldFilter that does the same as BaseFilter,
+// placing the timestamp, version, and log level ahead of the other Fields.
+func BaseFieldFilter() FieldFilter {
+	return func(lvl, threshold Level, fields []Field) []Field {
+		if fields == nil {
+			return nil
+		}
+
+		if exceedsThreshold(lvl, threshold) {
+			return nil
+		}
+
+		n := len(fields)
+		fields = append(fields, Field{}, Field{}, Field{})
+		copy(fields[3:], fields[:n])
+		fields[0] = String(TimestampKey, time.Now().UTC().Format(DefaultTimestampFormat))
+		fields[1] = String(VersionKey, "1")
+		fields[2] = Any(LevelKey, lvl)
+		return fields
+	}
+}
@@ -0,0 +1,146 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/fastjson"
+	mock_log "github.com/PermissionData/log/mock"
+)
+
+func TestFieldValue(t *testing.T) {
+	when := time.Date(2017, 1, 2, 3, 4, 5, 6, time.FixedZone("X", 3600))
+	far := time.Date(3000, 1, 2, 3, 4, 5, 6, time.FixedZone("X", 3600))
+
+	testCases := []struct {
+		name  string
+		field log.Field
+		want  interface{}
+	}{
+		{"string", log.String("k", "v"), "v"},
+		{"int", log.Int("k", -1), int64(-1)},
+		{"int64", log.Int64("k", 1<<60), int64(1 << 60)},
+		{"float64", log.Float64("k", 3.14), 3.14},
+		{"true", log.Bool("k", true), true},
+		{"false", log.Bool("k", false), false},
+		{"duration", log.Dur("k", time.Second), time.Second},
+		{"time", log.Time("k", when), when},
+		{"zero time", log.Time("k", time.Time{}), time.Time{}},
+		{"far time", log.Time("k", far), far},
+		{"error", log.Err("k", errors.New("oops")), "oops"},
+		{"nil error", log.Err("k", nil), nil},
+		{"any", log.Any("k", []int{1}), []int{1}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.field.Value(); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("%+v.Value() = %#v, expected %#v", tc.field, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFieldsData(t *testing.T) {
+	got := log.FieldsData([]log.Field{log.String("a", "first"), log.Int("b", 2), log.String("a", "last")})
+	want := log.Data{"a": "last", "b": int64(2)}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("FieldsData = %+v, expected %+v", got, want)
+	}
+}
+
+func TestBaseFieldFilter(t *testing.T) {
+	fields := log.BaseFieldFilter()(log.InfoLevel, log.InfoLevel, []log.Field{log.String("a", "b")})
+	if len(fields) != 4 {
+		t.Fatalf("BaseFieldFilter returned %d fields, expected 4: %+v", len(fields), fields)
+	}
+	for i, key := range []string{log.TimestampKey, log.VersionKey, log.LevelKey, "a"} {
+		if fields[i].Key != key {
+			t.Errorf("BaseFieldFilter put %q at %d, expected %q", fields[i].Key, i, key)
+		}
+	}
+	if lvl := fields[2].Value(); lvl != log.InfoLevel {
+		t.Errorf("BaseFieldFilter set level %v, expected %v", lvl, log.InfoLevel)
+	}
+
+	if fields := log.BaseFieldFilter()(log.TraceLevel, log.InfoLevel, []log.Field{}); fields != nil {
+		t.Errorf("BaseFieldFilter returned %+v above the threshold, expected nil", fields)
+	}
+	if fields := log.BaseFieldFilter()(log.InfoLevel, log.InfoLevel, nil); fields != nil {
+		t.Errorf("BaseFieldFilter returned %+v for nil fields, expected nil", fields)
+	}
+}
+
+func TestLogFieldsUsesFilters(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockEncoder := mock_log.NewMockEncoder(mockCtrl)
+	mockEncoder.EXPECT().Encode(gomock.Eq(log.Data{"pi": 3.14, "a": "b", "n": int64(1)})).Times(1)
+
+	lg := log.New(log.Config{
+		Encoder: mockEncoder,
+		FieldFilters: []log.FieldFilter{
+			func(lvl, threshold log.Level, fields []log.Field) []log.Field {
+				return append(fields, log.Int("n", 1))
+			},
+		},
+		Filters: []log.Filter{Pi},
+	})
+	log.LogFields(lg, log.InfoLevel, log.String("a", "b"))
+}
+
+func TestLogFieldsDropped(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockEncoder := mock_log.NewMockEncoder(mockCtrl)
+
+	lg := log.New(log.Config{Encoder: mockEncoder, Threshold: log.ErrorLevel})
+	log.LogFields(lg, log.InfoLevel, log.String("a", "b"))
+
+	lg = log.New(log.Config{
+		Encoder: mockEncoder,
+		FieldFilters: []log.FieldFilter{
+			func(lvl, threshold log.Level, fields []log.Field) []log.Field { return nil },
+		},
+	})
+	log.LogFields(lg, log.ErrorLevel, log.String("a", "b"))
+}
+
+func TestLogFieldsWithFieldEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	lg := log.WithLevels(log.New(log.Config{Encoder: fastjson.NewEncoder(&buf), Threshold: log.TraceLevel}))
+	log.LogFields(lg, log.ErrorLevel, log.String(log.MessageKey, "hello"), log.Err("error", errors.New("oops")))
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("LogFields wrote invalid JSON %q: %+v", buf.String(), err)
+	}
+	want := map[string]interface{}{
+		log.TimestampKey: got[log.TimestampKey],
+		log.VersionKey:   "1",
+		log.LevelKey:     "Error",
+		log.MessageKey:   "hello",
+		"error":          "oops",
+	}
+	if _, ok := got[log.TimestampKey].(string); !ok || !reflect.DeepEqual(got, want) {
+		t.Fatalf("LogFields wrote %+v, expected %+v", got, want)
+	}
+}
+
+func TestLogFieldsWithoutFieldLogger(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockLogger := mock_log.NewMockLogger(mockCtrl)
+	mockLogger.EXPECT().Log(log.InfoLevel, gomock.Eq(log.Data{"a": "b"})).Times(2)
+
+	log.LogFields(mockLogger, log.InfoLevel, log.String("a", "b"))
+	log.WithLevels(mockLogger).(log.FieldLogger).LogFields(log.InfoLevel, log.String("a", "b"))
+}
@@ -26,17 +26,23 @@ func BaseFilter() Filter {
 			return nil
 		}
 
-		if lvl <= TraceLevel && lvl > threshold {
+		if exceedsThreshold(lvl, threshold) {
 			return nil
 		}
 
-		data["@timestamp"] = time.Now().UTC().Format(DefaultTimestampFormat)
-		data["@version"] = "1"
-		data["log_level"] = lvl
+		data[TimestampKey] = time.Now().UTC().Format(DefaultTimestampFormat)
+		data[VersionKey] = "1"
+		data[LevelKey] = lvl
 		return data
 	}
 }
 
+// exceedsThreshold reports whether a standard Level is less severe than the
+// threshold. Custom Levels beyond TraceLevel are never discarded.
+func exceedsThreshold(lvl, threshold Level) bool {
+	return lvl <= TraceLevel && lvl > threshold
+}
+
 // StackFilter adds a stack trace to log data when the log level exceeds the threshold.
 func StackFilter(stackLevel Level) Filter {
 	return func(lvl, threshold Level, data Data) Data {
@@ -44,7 +50,7 @@ func StackFilter(stackLevel Level) Filter {
 			return nil
 		}
 		if lvl <= stackLevel {
-			data["_stack"] = string(debug.Stack())
+			data[StackKey] = string(debug.Stack())
 		}
 		return data
 	}
@@ -0,0 +1,321 @@
+package log
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FlattenConfig controls how nested values are collapsed by Flatten and
+// FlattenFilter.
+type FlattenConfig struct {
+	// Separator joins the keys of nested values. The default is ".".
+	Separator string
+	// MaxDepth limits how many keys are joined into a single key. Values nested
+	// deeper than MaxDepth are kept as they are. Zero means no limit.
+	MaxDepth int
+	// Arrays causes slices and arrays to be flattened with their indices as
+	// keys. Otherwise they are kept as they are.
+	Arrays bool
+}
+
+// DefaultSeparator is used to join keys when no Separator is configured.
+const DefaultSeparator = "."
+
+// FlattenFilter provides a Filter that collapses nested Data, maps, and
+// structs into a single level of Data with joined keys, as required by
+// graylog additional fields.
+func FlattenFilter(c FlattenConfig) Filter {
+	return func(lvl, threshold Level, data Data) Data {
+		if data == nil {
+			return nil
+		}
+		return Flatten(data, c)
+	}
+}
+
+// Flatten collapses nested values in data into a new Data with keys joined by
+// the configured Separator. Structs are flattened by their exported fields,
+// honoring `json` tags.
+func Flatten(data Data, c FlattenConfig) Data {
+	if c.Separator == "" {
+		c.Separator = DefaultSeparator
+	}
+	flat := make(Data, len(data))
+	for k, v := range data {
+		flattenValue(flat, k, v, 1, c, nil)
+	}
+	return flat
+}
+
+// maxNesting bounds recursion into nested values, so that values containing
+// themselves are left as they are rather than recursing forever.
+const maxNesting = 100
+
+// nestedRef returns a pointer identifying a map, slice, or pointer that may
+// contain itself, or 0 for other values.
+func nestedRef(rv reflect.Value) uintptr {
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Ptr:
+		return rv.Pointer()
+	}
+	return 0
+}
+
+// enter adds the reference of v to parents, reporting false when v is
+// already among them or nested too deeply.
+func enter(parents []uintptr, v interface{}) ([]uintptr, bool) {
+	if len(parents) >= maxNesting {
+		return parents, false
+	}
+	ref := nestedRef(reflect.ValueOf(v))
+	if ref == 0 {
+		return parents, true
+	}
+	for _, p := range parents {
+		if p == ref {
+			return parents, false
+		}
+	}
+	return append(parents, ref), true
+}
+
+func flattenValue(flat Data, key string, v interface{}, depth int, c FlattenConfig, parents []uintptr) {
+	if c.MaxDepth > 0 && depth >= c.MaxDepth {
+		flat[key] = v
+		return
+	}
+	switch val := v.(type) {
+	case nil, string, bool, int, int64, float64:
+		flat[key] = v
+		return
+	case Data:
+		if len(val) == 0 {
+			flat[key] = v
+			return
+		}
+	case map[string]interface{}:
+		if len(val) == 0 {
+			flat[key] = v
+			return
+		}
+	}
+	parents, ok := enter(parents, v)
+	if !ok {
+		flat[key] = v
+		return
+	}
+
+	switch val := v.(type) {
+	case Data:
+		for k, nested := range val {
+			flattenValue(flat, key+c.Separator+k, nested, depth+1, c, parents)
+		}
+		return
+	case map[string]interface{}:
+		for k, nested := range val {
+			flattenValue(flat, key+c.Separator+k, nested, depth+1, c, parents)
+		}
+		return
+	case []interface{}:
+		if !c.Arrays || len(val) == 0 {
+			flat[key] = v
+			return
+		}
+		for i, nested := range val {
+			flattenValue(flat, key+c.Separator+strconv.Itoa(i), nested, depth+1, c, parents)
+		}
+		return
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || rv.Len() == 0 {
+			break
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			flattenValue(flat, key+c.Separator+iter.Key().String(), iter.Value().Interface(), depth+1, c, parents)
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		if !c.Arrays || rv.Len() == 0 || rv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		for i := 0; i < rv.Len(); i++ {
+			flattenValue(flat, key+c.Separator+strconv.Itoa(i), rv.Index(i).Interface(), depth+1, c, parents)
+		}
+		return
+	case reflect.Struct:
+		if !flattenable(rv) {
+			break
+		}
+		fields := structFields(rv)
+		if len(fields) == 0 {
+			break
+		}
+		for _, f := range fields {
+			flattenValue(flat, key+c.Separator+f.name, f.value.Interface(), depth+1, c, parents)
+		}
+		return
+	}
+	flat[key] = v
+}
+
+// flattenable reports whether a struct should be broken into its fields rather
+// than left to marshal itself.
+func flattenable(rv reflect.Value) bool {
+	if !rv.CanInterface() {
+		return false
+	}
+	switch rv.Interface().(type) {
+	case json.Marshaler, encoding.TextMarshaler:
+		return false
+	}
+	if rv.CanAddr() {
+		switch rv.Addr().Interface().(type) {
+		case json.Marshaler, encoding.TextMarshaler:
+			return false
+		}
+	}
+	return true
+}
+
+type structField struct {
+	name  string
+	value reflect.Value
+}
+
+// structFields lists the exported fields of a struct using the names and
+// options encoding/json would use. Exported embedded structs without a name
+// are inlined.
+func structFields(rv reflect.Value) []structField {
+	var fields []structField
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+		fv := rv.Field(i)
+		if sf.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && fv.CanInterface() {
+				fields = append(fields, structFields(fv)...)
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && isEmptyValue(fv) {
+			continue
+		}
+		fields = append(fields, structField{name: name, value: fv})
+	}
+	return fields
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// NestFilter provides a Filter that expands keys joined by separator into
+// nested Data, the inverse of FlattenFilter. A key that conflicts with a
+// non-nested value is kept as it is.
+func NestFilter(separator string) Filter {
+	return func(lvl, threshold Level, data Data) Data {
+		if data == nil {
+			return nil
+		}
+		return Nest(data, separator)
+	}
+}
+
+// Nest expands keys in data joined by separator into nested Data. Nested
+// Data already in data is copied before keys are added to it.
+func Nest(data Data, separator string) Data {
+	if separator == "" {
+		separator = DefaultSeparator
+	}
+	nested := make(Data, len(data))
+	var deferred []string
+	for k, v := range data {
+		if !strings.Contains(k, separator) {
+			nested[k] = v
+			continue
+		}
+		deferred = append(deferred, k)
+	}
+	sort.Strings(deferred)
+	owned := make(map[uintptr]bool, len(deferred))
+	for _, k := range deferred {
+		if !nestValue(nested, strings.Split(k, separator), data[k], owned) {
+			nested[k] = data[k]
+		}
+	}
+	return nested
+}
+
+// nestValue sets v at path under parent, copying any Data along the path that
+// is not in owned, so that Data belonging to the caller is not modified.
+func nestValue(parent Data, path []string, v interface{}, owned map[uintptr]bool) bool {
+	if len(path) == 1 {
+		if _, exists := parent[path[0]]; exists {
+			return false
+		}
+		parent[path[0]] = v
+		return true
+	}
+	child, exists := parent[path[0]]
+	if !exists {
+		child = Data{}
+		parent[path[0]] = child
+		owned[reflect.ValueOf(child).Pointer()] = true
+	}
+	childData, ok := child.(Data)
+	if !ok {
+		return false
+	}
+	if !owned[reflect.ValueOf(childData).Pointer()] {
+		copied := make(Data, len(childData)+1)
+		for k, nested := range childData {
+			copied[k] = nested
+		}
+		childData = copied
+		parent[path[0]] = childData
+		owned[reflect.ValueOf(childData).Pointer()] = true
+	}
+	return nestValue(childData, path[1:], v, owned)
+}
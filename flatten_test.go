@@ -0,0 +1,228 @@
+package log_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+)
+
+func TestFlattenFilter(t *testing.T) {
+	type Inner struct {
+		Name    string `json:"name"`
+		Skipped string `json:"-"`
+		Empty   string `json:",omitempty"`
+		Count   int
+	}
+	type outer struct {
+		Inner
+		When time.Time `json:"when"`
+	}
+	when := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		config   log.FlattenConfig
+		inData   log.Data
+		wantData log.Data
+	}{
+		{"nil data",
+			log.FlattenConfig{},
+			nil,
+			nil,
+		},
+		{"flat data",
+			log.FlattenConfig{},
+			log.Data{"pi": 3.14},
+			log.Data{"pi": 3.14},
+		},
+		{"nested data",
+			log.FlattenConfig{},
+			log.Data{
+				"req": log.Data{
+					"method": "GET",
+					"headers": map[string]interface{}{
+						"accept": "*/*",
+					},
+				},
+			},
+			log.Data{
+				"req.method":         "GET",
+				"req.headers.accept": "*/*",
+			},
+		},
+		{"underscore separator",
+			log.FlattenConfig{Separator: "_"},
+			log.Data{"req": log.Data{"method": "GET"}},
+			log.Data{"req_method": "GET"},
+		},
+		{"max depth",
+			log.FlattenConfig{MaxDepth: 2},
+			log.Data{"a": log.Data{"b": log.Data{"c": 1}}},
+			log.Data{"a.b": log.Data{"c": 1}},
+		},
+		{"arrays kept",
+			log.FlattenConfig{},
+			log.Data{"list": []interface{}{1, 2}},
+			log.Data{"list": []interface{}{1, 2}},
+		},
+		{"arrays flattened",
+			log.FlattenConfig{Arrays: true},
+			log.Data{
+				"list":  []interface{}{1, log.Data{"x": 2}},
+				"ints":  []int{3},
+				"bytes": []byte("raw"),
+			},
+			log.Data{
+				"list.0":   1,
+				"list.1.x": 2,
+				"ints.0":   3,
+				"bytes":    []byte("raw"),
+			},
+		},
+		{"typed map",
+			log.FlattenConfig{},
+			log.Data{"m": map[string]int{"one": 1}},
+			log.Data{"m.one": 1},
+		},
+		{"structs",
+			log.FlattenConfig{},
+			log.Data{"s": &outer{Inner: Inner{Name: "foo", Skipped: "bar", Count: 2}, When: when}},
+			log.Data{
+				"s.name":  "foo",
+				"s.Count": 2,
+				"s.when":  when,
+			},
+		},
+		{"empty nested values",
+			log.FlattenConfig{},
+			log.Data{"d": log.Data{}, "s": struct{}{}},
+			log.Data{"d": log.Data{}, "s": struct{}{}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			gotData := log.FlattenFilter(tc.config)(log.InfoLevel, log.InfoLevel, tc.inData)
+			if !reflect.DeepEqual(gotData, tc.wantData) {
+				t.Fatalf("FlattenFilter(%+v)(InfoLevel, InfoLevel, %+v) = %+v, expected %+v", tc.config, tc.inData, gotData, tc.wantData)
+			}
+		})
+	}
+}
+
+func TestNestFilter(t *testing.T) {
+	testCases := []struct {
+		name      string
+		separator string
+		inData    log.Data
+		wantData  log.Data
+	}{
+		{"nil data",
+			".",
+			nil,
+			nil,
+		},
+		{"flat data",
+			".",
+			log.Data{"pi": 3.14},
+			log.Data{"pi": 3.14},
+		},
+		{"dotted keys",
+			".",
+			log.Data{
+				"req.method":         "GET",
+				"req.headers.accept": "*/*",
+				"pi":                 3.14,
+			},
+			log.Data{
+				"req": log.Data{
+					"method": "GET",
+					"headers": log.Data{
+						"accept": "*/*",
+					},
+				},
+				"pi": 3.14,
+			},
+		},
+		{"default separator",
+			"",
+			log.Data{"a.b": 1},
+			log.Data{"a": log.Data{"b": 1}},
+		},
+		{"underscore separator",
+			"_",
+			log.Data{"a_b": 1, "a.c": 2},
+			log.Data{"a": log.Data{"b": 1}, "a.c": 2},
+		},
+		{"conflicting keys",
+			".",
+			log.Data{"a": "flat", "a.b": 1, "c.d": 2, "c.d.e": 3},
+			log.Data{"a": "flat", "a.b": 1, "c": log.Data{"d": 2}, "c.d.e": 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			gotData := log.NestFilter(tc.separator)(log.InfoLevel, log.InfoLevel, tc.inData)
+			if !reflect.DeepEqual(gotData, tc.wantData) {
+				t.Fatalf("NestFilter(%q)(InfoLevel, InfoLevel, %+v) = %+v, expected %+v", tc.separator, tc.inData, gotData, tc.wantData)
+			}
+		})
+	}
+}
+
+func TestNestCopiesData(t *testing.T) {
+	req := log.Data{"method": "GET"}
+	data := log.Data{"req": req, "req.path": "/", "empty": log.Data(nil), "empty.a": 1}
+	got := log.Nest(data, ".")
+
+	want := log.Data{"req": log.Data{"method": "GET", "path": "/"}, "empty": log.Data{"a": 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Nest(%+v) = %+v, expected %+v", data, got, want)
+	}
+	if !reflect.DeepEqual(req, log.Data{"method": "GET"}) {
+		t.Errorf("Nest modified the nested Data to %+v", req)
+	}
+}
+
+func TestFlattenNestRoundTrip(t *testing.T) {
+	data := log.Data{
+		"req": log.Data{
+			"method": "GET",
+			"url":    log.Data{"path": "/", "query": "a=b"},
+		},
+		"pi": 3.14,
+	}
+	got := log.Nest(log.Flatten(data, log.FlattenConfig{}), log.DefaultSeparator)
+	if !reflect.DeepEqual(got, data) {
+		t.Fatalf("Nest(Flatten(%+v)) = %+v, expected the original", data, got)
+	}
+}
+
+func TestFlattenCycle(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+	}
+	loop := &node{Name: "a"}
+	loop.Next = loop
+
+	data := log.Data{"a": 1}
+	data["x"] = data
+	data["y"] = data
+	data["loop"] = loop
+
+	flat := log.Flatten(data, log.FlattenConfig{})
+	for _, k := range []string{"x.x", "x.y", "y.x", "y.y"} {
+		if v, ok := flat[k].(log.Data); !ok || reflect.ValueOf(v).Pointer() != reflect.ValueOf(data).Pointer() {
+			t.Errorf("Flatten kept %s as %T, expected the Data containing itself", k, flat[k])
+		}
+	}
+	if flat["x.a"] != 1 || flat["y.x"] == nil || flat["loop.Name"] != "a" || flat["loop.Next"] != loop {
+		t.Errorf("Flatten = %v, expected values up to the first repeat", flat)
+	}
+}
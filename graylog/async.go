@@ -0,0 +1,173 @@
+package graylog
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueuePolicy selects what an asynchronous Client does with a message written
+// while its queue is full.
+type QueuePolicy int
+
+const (
+	// QueueBlock waits for room in the queue.
+	QueueBlock QueuePolicy = iota
+	// QueueDrop discards the message, returning ErrQueueFull.
+	QueueDrop
+)
+
+func (qp QueuePolicy) String() string {
+	switch qp {
+	case QueueBlock:
+		return "block"
+	case QueueDrop:
+		return "drop"
+	}
+	return fmt.Sprintf("QueuePolicy(%d)", int(qp))
+}
+
+// ErrQueueFull is returned when a message is dropped by the QueueDrop policy.
+var ErrQueueFull = errors.New("queue is full")
+
+// DefaultCloseTimeout is how long Flush and Close wait for queued messages to
+// be sent when Config.CloseTimeout is not set.
+const DefaultCloseTimeout = 5 * time.Second
+
+// asyncQueue holds messages waiting to be compressed and sent by workers.
+type asyncQueue struct {
+	messages     chan []byte
+	policy       QueuePolicy
+	timeout      time.Duration
+	errorHandler func(error)
+
+	// mux is held for reading while queueing, so that messages is not closed
+	// by Close during a send.
+	mux    sync.RWMutex
+	closed bool
+
+	pendingMux sync.Mutex
+	pending    int
+	idle       []chan struct{}
+}
+
+func (gl *Client) startWorkers(c Config) {
+	q := &asyncQueue{
+		messages:     make(chan []byte, c.QueueSize),
+		policy:       c.QueueFull,
+		timeout:      c.CloseTimeout,
+		errorHandler: c.ErrorHandler,
+	}
+	gl.queue = q
+	for i := 0; i < c.Workers; i++ {
+		go func() {
+			for p := range q.messages {
+				if _, err := gl.write(p); err != nil {
+					q.errorHandler(err)
+				}
+				q.done()
+			}
+		}()
+	}
+}
+
+// enqueue adds a copy of p to the queue, following the QueuePolicy when it is
+// full.
+func (q *asyncQueue) enqueue(p []byte) (int, error) {
+	q.mux.RLock()
+	defer q.mux.RUnlock()
+	if q.closed {
+		return 0, ErrClosed
+	}
+	q.add()
+
+	msg := append(make([]byte, 0, len(p)), p...)
+	if q.policy == QueueDrop {
+		select {
+		case q.messages <- msg:
+			return len(p), nil
+		default:
+			q.done()
+			return 0, ErrQueueFull
+		}
+	}
+	q.messages <- msg
+	return len(p), nil
+}
+
+func (q *asyncQueue) add() {
+	q.pendingMux.Lock()
+	q.pending++
+	q.pendingMux.Unlock()
+}
+
+// done marks a message as sent or dropped, waking any Flush once none are
+// pending.
+func (q *asyncQueue) done() {
+	q.pendingMux.Lock()
+	q.pending--
+	if q.pending == 0 {
+		for _, idle := range q.idle {
+			close(idle)
+		}
+		q.idle = nil
+	}
+	q.pendingMux.Unlock()
+}
+
+// Flush waits up to Config.CloseTimeout for every queued message to be sent,
+// returning an error if any remain. It returns immediately for a synchronous
+// Client.
+func (gl *Client) Flush() error {
+	if gl.queue == nil {
+		return nil
+	}
+	return gl.queue.wait()
+}
+
+func (q *asyncQueue) wait() error {
+	q.pendingMux.Lock()
+	if q.pending == 0 {
+		q.pendingMux.Unlock()
+		return nil
+	}
+	idle := make(chan struct{})
+	q.idle = append(q.idle, idle)
+	q.pendingMux.Unlock()
+
+	timer := time.NewTimer(q.timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return nil
+	case <-timer.C:
+		q.pendingMux.Lock()
+		pending := q.pending
+		q.pendingMux.Unlock()
+		return fmt.Errorf("%d messages unsent after %v", pending, q.timeout)
+	}
+}
+
+// Close stops the Client accepting messages. An asynchronous Client waits up
+// to Config.CloseTimeout for queued messages to be sent, returning an error if
+// any remain. Writes after Close return ErrClosed. The ClientPacketConn is not
+// closed.
+func (gl *Client) Close() error {
+	q := gl.queue
+	if q == nil {
+		atomic.StoreInt32(&gl.closed, 1)
+		return nil
+	}
+
+	q.mux.Lock()
+	if q.closed {
+		q.mux.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.messages)
+	q.mux.Unlock()
+	return q.wait()
+}
@@ -0,0 +1,250 @@
+package graylog_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log/graylog"
+)
+
+// gatedPacketConn records the packets written to it, blocking each write
+// until the gate is opened. Writes waiting at the gate are signaled on
+// waiting.
+type gatedPacketConn struct {
+	nopPacketConn
+	gate    chan struct{}
+	waiting chan struct{}
+	err     error
+
+	mux     sync.Mutex
+	packets [][]byte
+}
+
+func newGatedPacketConn() *gatedPacketConn {
+	return &gatedPacketConn{gate: make(chan struct{}), waiting: make(chan struct{}, 1)}
+}
+
+func (c *gatedPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	select {
+	case c.waiting <- struct{}{}:
+	default:
+	}
+	<-c.gate
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.packets = append(c.packets, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (c *gatedPacketConn) count() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.packets)
+}
+
+func TestAsyncWrite(t *testing.T) {
+	conn := newGatedPacketConn()
+	c, err := graylog.New(graylog.Config{
+		Compression:      graylog.CompressNone,
+		QueueSize:        10,
+		Workers:          2,
+		ClientPacketConn: conn,
+	})
+	if err != nil {
+		t.Fatalf("error constructing New Client: %+v", err)
+	}
+
+	p := []byte(`{"short_message":"hi"}` + "\n")
+	for i := 0; i < 5; i++ {
+		if n, err := c.Write(p); n != len(p) || err != nil {
+			t.Fatalf("Write() = %d, %+v, expected %d, nil", n, err, len(p))
+		}
+	}
+	// the queued message must not share memory with p
+	p[2] = 'X'
+	if n := conn.count(); n != 0 {
+		t.Fatalf("%d packets sent before the connection was ready", n)
+	}
+
+	close(conn.gate)
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush returned unexpected error: %+v", err)
+	}
+	if n := conn.count(); n != 5 {
+		t.Fatalf("%d packets sent, expected 5", n)
+	}
+	for _, packet := range conn.packets {
+		if string(packet) != `{"short_message":"hi"}` {
+			t.Errorf("sent %q, expected the message as written", packet)
+		}
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close returned unexpected error: %+v", err)
+	}
+	if _, err := c.Write(p); err != graylog.ErrClosed {
+		t.Errorf("Write after Close returned %v, expected ErrClosed", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close returned unexpected error: %+v", err)
+	}
+}
+
+func TestAsyncQueueFull(t *testing.T) {
+	testCases := []struct {
+		name    string
+		policy  graylog.QueuePolicy
+		wantErr error
+	}{
+		{"drop", graylog.QueueDrop, graylog.ErrQueueFull},
+		{"block", graylog.QueueBlock, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := newGatedPacketConn()
+			c, err := graylog.New(graylog.Config{
+				QueueSize:        1,
+				QueueFull:        tc.policy,
+				ClientPacketConn: conn,
+			})
+			if err != nil {
+				t.Fatalf("error constructing New Client: %+v", err)
+			}
+
+			p := []byte("{}\n")
+			// the worker holds the first message and the queue the second
+			c.Write(p)
+			<-conn.waiting
+			c.Write(p)
+
+			written := make(chan error)
+			go func() {
+				_, err := c.Write(p)
+				written <- err
+			}()
+			select {
+			case err := <-written:
+				if tc.wantErr == nil {
+					t.Fatalf("Write returned %v without waiting for room", err)
+				}
+				if err != tc.wantErr {
+					t.Errorf("Write returned %v, expected %v", err, tc.wantErr)
+				}
+			case <-time.After(50 * time.Millisecond):
+				if tc.wantErr != nil {
+					t.Fatalf("Write blocked, expected %v", tc.wantErr)
+				}
+			}
+
+			close(conn.gate)
+			if tc.wantErr == nil {
+				if err := <-written; err != nil {
+					t.Errorf("blocked Write returned unexpected error: %+v", err)
+				}
+			}
+			if err := c.Close(); err != nil {
+				t.Fatalf("Close returned unexpected error: %+v", err)
+			}
+			want := 3
+			if tc.wantErr != nil {
+				want = 2
+			}
+			if n := conn.count(); n != want {
+				t.Errorf("%d packets sent, expected %d", n, want)
+			}
+		})
+	}
+}
+
+func TestAsyncErrorHandler(t *testing.T) {
+	conn := newGatedPacketConn()
+	conn.err = errors.New("network is down")
+	close(conn.gate)
+
+	errs := make(chan error, 1)
+	c, err := graylog.New(graylog.Config{
+		QueueSize:        1,
+		ErrorHandler:     func(err error) { errs <- err },
+		ClientPacketConn: conn,
+	})
+	if err != nil {
+		t.Fatalf("error constructing New Client: %+v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Write([]byte("{}\n")); err != nil {
+		t.Fatalf("Write returned unexpected error: %+v", err)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Errorf("ErrorHandler called with nil error")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("ErrorHandler was not called")
+	}
+}
+
+func TestAsyncFlushTimeout(t *testing.T) {
+	conn := newGatedPacketConn()
+	defer close(conn.gate)
+	c, err := graylog.New(graylog.Config{
+		QueueSize:        1,
+		CloseTimeout:     10 * time.Millisecond,
+		ClientPacketConn: conn,
+	})
+	if err != nil {
+		t.Fatalf("error constructing New Client: %+v", err)
+	}
+
+	c.Write([]byte("{}\n"))
+	if err := c.Flush(); err == nil {
+		t.Errorf("Flush returned no error with a message unsent")
+	}
+	if err := c.Close(); err == nil {
+		t.Errorf("Close returned no error with a message unsent")
+	}
+}
+
+func TestSyncClose(t *testing.T) {
+	c, err := graylog.New(graylog.Config{ClientPacketConn: nopPacketConn{}})
+	if err != nil {
+		t.Fatalf("error constructing New Client: %+v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Errorf("Flush returned unexpected error: %+v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close returned unexpected error: %+v", err)
+	}
+	if _, err := c.Write([]byte("{}\n")); err != graylog.ErrClosed {
+		t.Errorf("Write after Close returned %v, expected ErrClosed", err)
+	}
+}
+
+func TestNew_Queue(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config graylog.Config
+	}{
+		{"negative queue size", graylog.Config{QueueSize: -1}},
+		{"negative workers", graylog.Config{QueueSize: 1, Workers: -1}},
+		{"invalid policy", graylog.Config{QueueSize: 1, QueueFull: graylog.QueueDrop + 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.ClientPacketConn = nopPacketConn{}
+			if _, err := graylog.New(tc.config); err == nil {
+				t.Errorf("New returned no error")
+			}
+		})
+	}
+}
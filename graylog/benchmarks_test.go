@@ -286,6 +286,57 @@ func BenchmarkWrite(b *testing.B) {
 	})
 }
 
+func BenchmarkWriteCompression(b *testing.B) {
+	s := []byte(fmt.Sprintf("{\"long string\" : \"%s\"}\n", strings.Repeat("pop ", 100)))
+	for _, bc := range []struct {
+		name        string
+		compression graylog.Compression
+	}{
+		{"gzip", graylog.CompressGzip},
+		{"zlib", graylog.CompressZlib},
+		{"none", graylog.CompressNone},
+	} {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			c, _ := graylog.New(graylog.Config{
+				Compression:      bc.compression,
+				CompressionLevel: gzip.BestSpeed,
+				ClientPacketConn: nopPacketConn{},
+			})
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c.Write(s)
+			}
+		})
+	}
+}
+
+func BenchmarkWriteAsync(b *testing.B) {
+	s := []byte(fmt.Sprintf("{\"long string\" : \"%s\"}\n", strings.Repeat("pop ", 100)))
+	for _, bc := range []struct {
+		name   string
+		config graylog.Config
+	}{
+		{"sync", graylog.Config{}},
+		{"Workers=1", graylog.Config{QueueSize: 1024}},
+		{"Workers=4", graylog.Config{QueueSize: 1024, Workers: 4}},
+	} {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			bc.config.CompressionLevel = gzip.BestSpeed
+			bc.config.ClientPacketConn = nopPacketConn{}
+			c, _ := graylog.New(bc.config)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					c.Write(s)
+				}
+			})
+			c.Flush()
+		})
+	}
+}
+
 type nopPacketConn struct{}
 
 func (nopPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
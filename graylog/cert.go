@@ -0,0 +1,75 @@
+package graylog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader loads a certificate and key from files, loading them again
+// whenever either file is modified, so that rotated certificates are used
+// without restarting. New certificates take effect on the next connection.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mux      sync.Mutex
+	cert     *tls.Certificate
+	certTime time.Time
+	keyTime  time.Time
+}
+
+// NewCertReloader creates a CertReloader, returning an error if the
+// certificate cannot be loaded.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	cr := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := cr.Certificate(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Certificate returns the current certificate, loading it again if either
+// file has been modified since it was last loaded. If loading fails, the
+// previous certificate is kept and the error is returned.
+func (cr *CertReloader) Certificate() (*tls.Certificate, error) {
+	cr.mux.Lock()
+	defer cr.mux.Unlock()
+
+	certInfo, err := os.Stat(cr.certFile)
+	if err != nil {
+		return cr.cert, fmt.Errorf("reading certificate: %+v", err)
+	}
+	keyInfo, err := os.Stat(cr.keyFile)
+	if err != nil {
+		return cr.cert, fmt.Errorf("reading key: %+v", err)
+	}
+	if cr.cert != nil && certInfo.ModTime().Equal(cr.certTime) && keyInfo.ModTime().Equal(cr.keyTime) {
+		return cr.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return cr.cert, fmt.Errorf("loading certificate: %+v", err)
+	}
+	cr.cert = &cert
+	cr.certTime = certInfo.ModTime()
+	cr.keyTime = keyInfo.ModTime()
+	return cr.cert, nil
+}
+
+// GetClientCertificate can be used as tls.Config.GetClientCertificate. The
+// previous certificate is used when loading a modified one fails.
+func (cr *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, err := cr.Certificate()
+	if cert != nil {
+		return cert, nil
+	}
+	return nil, err
+}
+
+// GetCertificate can be used as tls.Config.GetCertificate by servers.
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.GetClientCertificate(nil)
+}
@@ -72,3 +72,74 @@ func ExampleNew() {
 
 	logger.Log(log.InfoLevel, log.Data{})
 }
+
+func ExampleNewEncoder() {
+	conn, err := net.ListenPacket("udp4", "0.0.0.0:9092")
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	serverAddr, err := net.ResolveUDPAddr("udp4", "127.0.0.1:12201")
+	if err != nil {
+		panic(err)
+	}
+	gw, err := graylog.New(graylog.Config{
+		ClientPacketConn: conn,
+		ServerAddr:       serverAddr,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	logger := log.New(log.Config{
+		Threshold: log.InfoLevel,
+		Encoder:   graylog.NewEncoder(gw, "web-01"),
+	})
+
+	// sent as {"_user":"jdoe","host":"web-01","level":6,"short_message":"user logged in",...}
+	logger.Log(log.InfoLevel, log.Data{
+		"message": "user logged in",
+		"user":    "jdoe",
+	})
+}
+
+func ExampleNewTCP() {
+	tc, err := graylog.NewTCP(graylog.TCPConfig{ServerAddr: "127.0.0.1:12201"})
+	if err != nil {
+		panic(err)
+	}
+	defer tc.Close()
+
+	logger := log.New(log.Config{
+		Threshold: log.InfoLevel,
+		Encoder:   graylog.NewEncoder(tc, "web-01"),
+	})
+
+	// messages of any size are sent, reconnecting as needed
+	logger.Log(log.InfoLevel, log.Data{
+		"message": "user logged in",
+		"user":    "jdoe",
+	})
+}
+
+func ExampleNewHTTP() {
+	hc, err := graylog.NewHTTP(graylog.HTTPConfig{
+		URL:      "http://127.0.0.1:12201/gelf",
+		Compress: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer hc.Close()
+
+	logger := log.New(log.Config{
+		Threshold: log.InfoLevel,
+		Encoder:   graylog.NewEncoder(hc, "web-01"),
+	})
+
+	logger.Log(log.InfoLevel, log.Data{
+		"message": "user logged in",
+		"user":    "jdoe",
+	})
+}
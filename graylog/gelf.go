@@ -0,0 +1,244 @@
+package graylog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/internal/logdata"
+)
+
+// GELFVersion is the version of the GELF specification the Encoder produces.
+const GELFVersion = "1.1"
+
+var (
+	// ErrReservedField is reported when Data would produce the additional field
+	// "_id", which graylog reserves, and the field is renamed "_id_".
+	ErrReservedField = errors.New("additional field _id is reserved")
+	// ErrInvalidField is returned when Data would produce an additional field
+	// name graylog does not accept.
+	ErrInvalidField = errors.New("invalid additional field name")
+)
+
+var validFieldName = regexp.MustCompile(`^[\w\.\-]*$`)
+
+var _ log.Encoder = &Encoder{}
+
+// Encoder writes log Data as GELF messages, each followed by a newline, so
+// that it can write directly to a Client.
+type Encoder struct {
+	enc          *json.Encoder
+	host         string
+	errorHandler func(error)
+}
+
+// NewEncoder returns an Encoder that writes GELF messages to w, using host
+// for any Data without a "host" value. The hostname of the machine is used
+// when host is empty.
+func NewEncoder(w io.Writer, host string) *Encoder {
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return &Encoder{enc: json.NewEncoder(w), host: host, errorHandler: log.DefaultErrorHandler}
+}
+
+// SetErrorHandler sets the function that is passed ErrReservedField when a
+// field is renamed. The default is log.DefaultErrorHandler.
+func (enc *Encoder) SetErrorHandler(h func(error)) {
+	enc.errorHandler = h
+}
+
+// Encode writes the GELF encoding of v, which must be log.Data or a
+// map[string]interface{}. Additional fields with invalid names are rejected
+// with an error.
+func (enc *Encoder) Encode(v interface{}) error {
+	data, err := logdata.Assert("gelf", v)
+	if err != nil {
+		return err
+	}
+
+	lvl := log.InfoLevel
+	switch val := data[log.LevelKey].(type) {
+	case log.Level:
+		lvl = val
+	case string:
+		lvl.UnmarshalText([]byte(val))
+	}
+
+	msg, err := gelfMessage(lvl, data, enc.host, true, enc.errorHandler)
+	if err != nil {
+		return err
+	}
+	return enc.enc.Encode(msg)
+}
+
+// GELFFilter provides a Filter that converts log Data into a GELF message, so
+// that any Encoder may be used to send it. Unlike the Encoder, invalid
+// characters in additional field names are replaced.
+func GELFFilter(host string) log.Filter {
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return func(lvl, threshold log.Level, data log.Data) log.Data {
+		if data == nil {
+			return nil
+		}
+		msg, _ := gelfMessage(lvl, data, host, false, nil)
+		return msg
+	}
+}
+
+// SyslogLevel maps a Level to the syslog severity GELF uses for its level.
+func SyslogLevel(lvl log.Level) int {
+	switch lvl {
+	case log.FatalLevel:
+		return 2 // critical
+	case log.ErrorLevel:
+		return 3 // error
+	case log.InfoLevel:
+		return 6 // informational
+	}
+	return 7 // debug
+}
+
+// gelfMessage maps Data to the fields of a GELF message. Nested values are
+// flattened with underscores, since graylog does not accept nested additional
+// fields. "_id" is renamed "_id_", with ErrReservedField passed to report if
+// it is not nil. When strict, invalid field names are errors rather than being
+// replaced.
+func gelfMessage(lvl log.Level, data log.Data, host string, strict bool, report func(error)) (log.Data, error) {
+	msg := log.Data{
+		"version":       GELFVersion,
+		"host":          host,
+		"short_message": "-",
+		"timestamp":     gelfTimestamp(data[log.TimestampKey]),
+		"level":         SyslogLevel(lvl),
+	}
+
+	flat := log.Flatten(data, log.FlattenConfig{Separator: "_"})
+	for k, v := range flat {
+		switch k {
+		case log.TimestampKey, log.VersionKey, log.LevelKey:
+			continue
+		case log.MessageKey:
+			// an explicit short_message takes precedence
+			if gelfString(flat["short_message"]) != "" {
+				continue
+			}
+			fallthrough
+		case "host", "short_message":
+			if s := gelfString(v); s != "" {
+				msg[gelfStandardField(k)] = s
+			}
+			continue
+		case log.StackKey:
+			// an explicit full_message takes precedence
+			if gelfString(flat["full_message"]) != "" {
+				continue
+			}
+			fallthrough
+		case "full_message":
+			if s := gelfString(v); s != "" {
+				msg["full_message"] = s
+			}
+			continue
+		}
+
+		if v == nil {
+			continue
+		}
+		name := k
+		if !strings.HasPrefix(name, "_") {
+			name = "_" + name
+		}
+		if name == "_id" {
+			name = "_id_"
+			if report != nil {
+				report(fmt.Errorf("%v: renamed %q to %q", ErrReservedField, k, name))
+			}
+		}
+		if !validFieldName.MatchString(name) {
+			if strict {
+				return nil, fmt.Errorf("%v: %q", ErrInvalidField, name)
+			}
+			name = sanitizeFieldName(name)
+		}
+		msg[name] = gelfValue(v)
+	}
+	return msg, nil
+}
+
+func gelfStandardField(k string) string {
+	if k == log.MessageKey {
+		return "short_message"
+	}
+	return k
+}
+
+// gelfTimestamp converts a timestamp to seconds since the epoch, using the
+// current time when it cannot be parsed.
+func gelfTimestamp(v interface{}) float64 {
+	t := time.Now()
+	switch ts := v.(type) {
+	case time.Time:
+		t = ts
+	case string:
+		if parsed, err := time.Parse(log.DefaultTimestampFormat, ts); err == nil {
+			t = parsed
+		}
+	}
+	return float64(t.UnixNano()/int64(time.Millisecond)) / 1000
+}
+
+// gelfValue converts v to a string or number, the only values GELF allows
+// for additional fields.
+func gelfValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string, float64, float32, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, json.Number:
+		return v
+	case log.Level:
+		return val.String()
+	}
+	return gelfString(v)
+}
+
+func gelfString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case error:
+		return val.Error()
+	case json.Marshaler:
+	case fmt.Stringer:
+		return val.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		// not fmt.Sprint, which recurses forever on values containing themselves
+		return err.Error()
+	}
+	return strings.Trim(string(b), `"`)
+}
+
+func sanitizeFieldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '.' || r == '-' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
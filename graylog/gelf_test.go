@@ -0,0 +1,278 @@
+package graylog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/graylog"
+)
+
+func TestEncoderEncode(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         interface{}
+		want          map[string]interface{}
+		expectIsError bool
+	}{
+		{"base filter output",
+			log.Data{
+				log.TimestampKey: "2017-01-02T03:04:05.678Z",
+				log.VersionKey:   "1",
+				log.LevelKey:     log.ErrorLevel,
+				log.MessageKey:   "request failed",
+				log.StackKey:     "goroutine 1 [running]:",
+				"status":         500,
+				"ok":             false,
+				"err":            fmt.Errorf("timeout"),
+				"req":            log.Data{"method": "GET", "path": "/"},
+			},
+			map[string]interface{}{
+				"version":       "1.1",
+				"host":          "test-host",
+				"short_message": "request failed",
+				"full_message":  "goroutine 1 [running]:",
+				"timestamp":     1483326245.678,
+				"level":         float64(3),
+				"_status":       float64(500),
+				"_ok":           "false",
+				"_err":          "timeout",
+				"_req_method":   "GET",
+				"_req_path":     "/",
+			},
+			false,
+		},
+		{"level as text and host override",
+			map[string]interface{}{
+				log.TimestampKey: "2017-01-02T03:04:05.678Z",
+				log.LevelKey:     "Trace",
+				"host":           "other-host",
+				"short_message":  "hi",
+				"_already":       "prefixed",
+				"nothing":        nil,
+			},
+			map[string]interface{}{
+				"version":       "1.1",
+				"host":          "other-host",
+				"short_message": "hi",
+				"timestamp":     1483326245.678,
+				"level":         float64(7),
+				"_already":      "prefixed",
+			},
+			false,
+		},
+		{"missing message",
+			log.Data{
+				log.TimestampKey: time.Date(2017, 1, 2, 3, 4, 5, 678000000, time.UTC),
+				log.LevelKey:     log.FatalLevel,
+			},
+			map[string]interface{}{
+				"version":       "1.1",
+				"host":          "test-host",
+				"short_message": "-",
+				"timestamp":     1483326245.678,
+				"level":         float64(2),
+			},
+			false,
+		},
+		{"fields named like GELF fields",
+			log.Data{
+				log.TimestampKey: "2017-01-02T03:04:05.678Z",
+				"version":        "2.0",
+				"timestamp":      "yesterday",
+				"level":          "high",
+			},
+			map[string]interface{}{
+				"version":       "1.1",
+				"host":          "test-host",
+				"short_message": "-",
+				"timestamp":     1483326245.678,
+				"level":         float64(6),
+				"_version":      "2.0",
+				"_timestamp":    "yesterday",
+				"_level":        "high",
+			},
+			false,
+		},
+		{"invalid field name",
+			log.Data{"bad key": 42},
+			nil,
+			true,
+		},
+		{"not data",
+			"hello",
+			nil,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := graylog.NewEncoder(&buf, "test-host").Encode(tc.input)
+			if (err != nil) != tc.expectIsError {
+				t.Fatalf("Encode(%+v) = %+v, expected error? %v", tc.input, err, tc.expectIsError)
+			}
+			if err != nil {
+				if buf.Len() != 0 {
+					t.Errorf("Encode(%+v) wrote %q despite an error", tc.input, buf.String())
+				}
+				return
+			}
+			if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+				t.Errorf("Encode(%+v) wrote %q, expected a trailing newline", tc.input, buf.String())
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Encode(%+v) wrote invalid JSON %q: %+v", tc.input, buf.String(), err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Encode(%+v) wrote %+v, expected %+v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncoderEncodeReservedField(t *testing.T) {
+	for _, key := range []string{"id", "_id"} {
+		var reported []error
+		var buf bytes.Buffer
+		enc := graylog.NewEncoder(&buf, "test-host")
+		enc.SetErrorHandler(func(err error) { reported = append(reported, err) })
+		if err := enc.Encode(log.Data{key: 42}); err != nil {
+			t.Fatalf("Encode(%s) returned unexpected error: %+v", key, err)
+		}
+		var got map[string]interface{}
+		json.Unmarshal(buf.Bytes(), &got)
+		if _, ok := got["_id"]; ok || got["_id_"] != float64(42) {
+			t.Errorf("Encode(%s) wrote %s, expected the field renamed _id_", key, buf.String())
+		}
+		if len(reported) != 1 || !strings.HasPrefix(reported[0].Error(), graylog.ErrReservedField.Error()) {
+			t.Errorf("Encode(%s) reported %v, expected one ErrReservedField", key, reported)
+		}
+	}
+}
+
+func TestEncoderEncodePrecedence(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input log.Data
+		short string
+		full  string
+	}{
+		{"explicit fields win",
+			log.Data{
+				log.MessageKey:  "a",
+				"short_message": "b",
+				log.StackKey:    "s",
+				"full_message":  "f",
+			},
+			"b", "f",
+		},
+		{"empty explicit fields",
+			log.Data{
+				log.MessageKey:  "a",
+				"short_message": "",
+				log.StackKey:    "s",
+				"full_message":  nil,
+			},
+			"a", "s",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// map iteration order varies, so encode repeatedly
+			for i := 0; i < 50; i++ {
+				var buf bytes.Buffer
+				if err := graylog.NewEncoder(&buf, "test-host").Encode(tc.input); err != nil {
+					t.Fatalf("Encode returned unexpected error: %+v", err)
+				}
+				var got map[string]interface{}
+				json.Unmarshal(buf.Bytes(), &got)
+				if got["short_message"] != tc.short || got["full_message"] != tc.full {
+					t.Fatalf("encoded short_message %v and full_message %v, expected %s and %s",
+						got["short_message"], got["full_message"], tc.short, tc.full)
+				}
+			}
+		})
+	}
+}
+
+func TestEncoderEncodeCycle(t *testing.T) {
+	data := log.Data{log.MessageKey: "hi"}
+	data["self"] = data
+
+	var buf bytes.Buffer
+	if err := graylog.NewEncoder(&buf, "test-host").Encode(data); err != nil {
+		t.Fatalf("Encode(<cycle>) returned unexpected error: %+v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Encode(<cycle>) wrote invalid JSON %q: %+v", buf.String(), err)
+	}
+	if got["short_message"] != "hi" {
+		t.Errorf("Encode(<cycle>) wrote short_message %v, expected hi", got["short_message"])
+	}
+	var cut bool
+	for _, v := range got {
+		if s, ok := v.(string); ok && strings.HasPrefix(s, "json: unsupported value: ") {
+			cut = true
+		}
+	}
+	if !cut {
+		t.Errorf("Encode(<cycle>) wrote %q, expected the encoding error for the cycle", buf.String())
+	}
+}
+
+func TestGELFFilter(t *testing.T) {
+	got := graylog.GELFFilter("test-host")(log.InfoLevel, log.InfoLevel, log.Data{
+		log.TimestampKey: "2017-01-02T03:04:05.678Z",
+		log.MessageKey:   "hello",
+		"id":             42,
+		"bad key":        "value",
+		"level_name":     log.InfoLevel,
+	})
+	want := log.Data{
+		"version":       "1.1",
+		"host":          "test-host",
+		"short_message": "hello",
+		"timestamp":     1483326245.678,
+		"level":         6,
+		"_id_":          42,
+		"_bad_key":      "value",
+		"_level_name":   "Info",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GELFFilter(...) = %+v, expected %+v", got, want)
+	}
+
+	if got := graylog.GELFFilter("test-host")(log.InfoLevel, log.InfoLevel, nil); got != nil {
+		t.Errorf("GELFFilter(...)(nil) = %+v, expected nil", got)
+	}
+}
+
+func TestSyslogLevel(t *testing.T) {
+	testCases := []struct {
+		lvl  log.Level
+		want int
+	}{
+		{log.FatalLevel, 2},
+		{log.ErrorLevel, 3},
+		{log.InfoLevel, 6},
+		{log.TraceLevel, 7},
+		{log.TraceLevel + 1, 7},
+	}
+	for _, tc := range testCases {
+		if got := graylog.SyslogLevel(tc.lvl); got != tc.want {
+			t.Errorf("SyslogLevel(%v) = %d, expected %d", tc.lvl, got, tc.want)
+		}
+	}
+}
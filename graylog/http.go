@@ -0,0 +1,254 @@
+package graylog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/PermissionData/log"
+)
+
+// HTTPClient is a Writer for the graylog GELF HTTP input. Messages are
+// posted in batches of newline separated messages, with optional gzip
+// compression, and retried when the server fails or does not respond.
+type HTTPClient struct {
+	config HTTPConfig
+
+	mux     sync.Mutex
+	batch   bytes.Buffer
+	count   int
+	zip     *gzip.Writer
+	closed  bool
+	done    chan struct{}
+	flusher sync.WaitGroup
+	// posting tracks batches sent after releasing mux.
+	posting sync.WaitGroup
+}
+
+// HTTPConfig is used to set up a new HTTPClient
+type HTTPConfig struct {
+	// URL is the GELF HTTP input, such as http://graylog:12201/gelf.
+	URL string
+	// Client sends the requests. The default is a client with a five second
+	// timeout.
+	Client *http.Client
+	// Headers are added to every request, such as for authentication.
+	Headers map[string]string
+	// BatchSize is the number of messages sent in each request. The default
+	// is 1, since batches require an input that accepts newline separated
+	// messages.
+	BatchSize int
+	// FlushInterval sends partial batches periodically when set. Errors from
+	// periodic sends are passed to ErrorHandler.
+	FlushInterval time.Duration
+	// ErrorHandler is called with errors from periodic sends. The default is
+	// log.DefaultErrorHandler.
+	ErrorHandler func(error)
+	// Compress sends requests with gzip Content-Encoding.
+	Compress bool
+	// CompressionLevel is the gzip level used when compressing.
+	CompressionLevel int
+	// MaxRetries is the number of times a request is retried after a server
+	// error or timeout. The default is 3, and a negative value disables
+	// retries.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the delay between retries, which
+	// doubles with each attempt. The defaults are 100 milliseconds and five
+	// seconds.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewHTTP creates an HTTPClient with the Config provided
+func NewHTTP(c HTTPConfig) (*HTTPClient, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("cannot create new HTTPClient without a URL")
+	}
+	if c.BatchSize < 0 {
+		return nil, fmt.Errorf("batch size of %d is not a valid batch size", c.BatchSize)
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 1
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+	if c.ErrorHandler == nil {
+		c.ErrorHandler = log.DefaultErrorHandler
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 100 * time.Millisecond
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = 5 * time.Second
+	}
+
+	hc := &HTTPClient{config: c, done: make(chan struct{})}
+	if c.Compress {
+		zip, err := gzip.NewWriterLevel(ioutil.Discard, c.CompressionLevel)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"compression level of %d is not a valid compression level",
+				c.CompressionLevel,
+			)
+		}
+		hc.zip = zip
+	}
+	if c.FlushInterval > 0 {
+		hc.flusher.Add(1)
+		go hc.flushPeriodically()
+	}
+	return hc, nil
+}
+
+// Write adds the contents of a byte slice to the current batch, sending the
+// batch once it is full. Like the UDP Client, p must end with a newline.
+func (hc *HTTPClient) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !bytes.HasSuffix(p, []byte("\n")) {
+		return 0, ErrMissingNewline
+	}
+
+	hc.mux.Lock()
+	if hc.closed {
+		hc.mux.Unlock()
+		return 0, ErrClosed
+	}
+
+	if hc.count > 0 {
+		hc.batch.WriteByte('\n')
+	}
+	hc.batch.Write(bytes.TrimFunc(p, unicode.IsSpace))
+	hc.count++
+	if hc.count < hc.config.BatchSize {
+		hc.mux.Unlock()
+		return len(p), nil
+	}
+	if err := hc.send(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush sends any messages in the current batch.
+func (hc *HTTPClient) Flush() error {
+	hc.mux.Lock()
+	return hc.send()
+}
+
+// Close stops any periodic sends and sends the remaining messages. Writes
+// after Close return ErrClosed.
+func (hc *HTTPClient) Close() error {
+	hc.mux.Lock()
+	if hc.closed {
+		hc.mux.Unlock()
+		return nil
+	}
+	hc.closed = true
+	close(hc.done)
+	hc.mux.Unlock()
+
+	hc.flusher.Wait()
+	err := hc.Flush()
+	hc.posting.Wait()
+	return err
+}
+
+func (hc *HTTPClient) flushPeriodically() {
+	defer hc.flusher.Done()
+	ticker := time.NewTicker(hc.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hc.done:
+			return
+		case <-ticker.C:
+			if err := hc.Flush(); err != nil {
+				hc.config.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+// send takes the current batch and posts it once hc.mux, which must be held,
+// is released, retrying with backoff. The batch is dropped once the retries
+// are exhausted.
+func (hc *HTTPClient) send() error {
+	if hc.count == 0 {
+		hc.mux.Unlock()
+		return nil
+	}
+	count, body := hc.count, hc.batch.Bytes()
+	var err error
+	if hc.zip != nil {
+		var buf bytes.Buffer
+		hc.zip.Reset(&buf)
+		hc.zip.Write(body)
+		err = hc.zip.Close()
+		body = buf.Bytes()
+	} else {
+		body = append([]byte(nil), body...)
+	}
+	hc.batch.Reset()
+	hc.count = 0
+	if err != nil {
+		hc.mux.Unlock()
+		return fmt.Errorf("compressing %d messages: %+v", count, err)
+	}
+	hc.posting.Add(1)
+	hc.mux.Unlock()
+	defer hc.posting.Done()
+
+	backoff := hc.config.MinBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := hc.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= hc.config.MaxRetries {
+			return fmt.Errorf("sending %d messages: %+v", count, err)
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > hc.config.MaxBackoff {
+			backoff = hc.config.MaxBackoff
+		}
+	}
+}
+
+// post makes a single request, reporting whether a failure may be retried.
+func (hc *HTTPClient) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, hc.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hc.zip != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range hc.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := hc.config.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false, nil
+	}
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
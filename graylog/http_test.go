@@ -0,0 +1,293 @@
+package graylog_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log/graylog"
+)
+
+// gelfHTTPServer records the bodies posted to it, responding with the
+// statuses queued in responses and 202 Accepted after that.
+type gelfHTTPServer struct {
+	*httptest.Server
+
+	mux       sync.Mutex
+	bodies    []string
+	encodings []string
+	responses []int
+	delay     time.Duration
+}
+
+func newGELFHTTPServer(t *testing.T, responses ...int) *gelfHTTPServer {
+	s := &gelfHTTPServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("unexpected error reading gzip body: %+v", err)
+				return
+			}
+			body = zr
+		}
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Errorf("unexpected error reading body: %+v", err)
+		}
+
+		s.mux.Lock()
+		status := http.StatusAccepted
+		if len(s.responses) > 0 {
+			status, s.responses = s.responses[0], s.responses[1:]
+		}
+		delay := s.delay
+		s.delay = 0
+		s.bodies = append(s.bodies, string(b))
+		s.encodings = append(s.encodings, r.Header.Get("Content-Encoding"))
+		s.mux.Unlock()
+
+		time.Sleep(delay)
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+func (s *gelfHTTPServer) received() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func TestHTTPClientBatches(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    graylog.HTTPConfig
+		wantBody  []string
+		wantAfter []string
+	}{
+		{"default batch",
+			graylog.HTTPConfig{},
+			[]string{`{"n":1}`, `{"n":2}`, `{"n":3}`},
+			nil,
+		},
+		{"batches of two",
+			graylog.HTTPConfig{BatchSize: 2},
+			[]string{"{\"n\":1}\n{\"n\":2}"},
+			[]string{"{\"n\":3}"},
+		},
+		{"compressed",
+			graylog.HTTPConfig{BatchSize: 3, Compress: true, CompressionLevel: gzip.BestSpeed},
+			[]string{"{\"n\":1}\n{\"n\":2}\n{\"n\":3}"},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := newGELFHTTPServer(t)
+			defer s.Close()
+
+			tc.config.URL = s.URL + "/gelf"
+			hc, err := graylog.NewHTTP(tc.config)
+			if err != nil {
+				t.Fatalf("unexpected error creating HTTPClient: %+v", err)
+			}
+			enc := json.NewEncoder(hc)
+			for i := 1; i <= 3; i++ {
+				if err := enc.Encode(map[string]int{"n": i}); err != nil {
+					t.Fatalf("Encode returned unexpected error: %+v", err)
+				}
+			}
+			if got := s.received(); strings.Join(got, "|") != strings.Join(tc.wantBody, "|") {
+				t.Fatalf("server received %q, expected %q", got, tc.wantBody)
+			}
+			if err := hc.Close(); err != nil {
+				t.Fatalf("Close returned unexpected error: %+v", err)
+			}
+			if got := s.received()[len(tc.wantBody):]; strings.Join(got, "|") != strings.Join(tc.wantAfter, "|") {
+				t.Fatalf("server received %q after Close, expected %q", got, tc.wantAfter)
+			}
+			for _, encoding := range s.encodings {
+				if (encoding == "gzip") != tc.config.Compress {
+					t.Errorf("server received Content-Encoding %q with Compress %v", encoding, tc.config.Compress)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPClientRetries(t *testing.T) {
+	testCases := []struct {
+		name       string
+		responses  []int
+		maxRetries int
+		wantErr    bool
+		wantPosts  int
+	}{
+		{"success", nil, 0, false, 1},
+		{"retried server errors", []int{500, 503}, 0, false, 3},
+		{"retries exhausted", []int{500, 500, 500}, 2, true, 3},
+		{"retries disabled", []int{500}, -1, true, 1},
+		{"client errors not retried", []int{400}, 0, true, 1},
+		{"rate limit retried", []int{429}, 0, false, 2},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := newGELFHTTPServer(t, tc.responses...)
+			defer s.Close()
+
+			hc, err := graylog.NewHTTP(graylog.HTTPConfig{
+				URL:        s.URL,
+				MaxRetries: tc.maxRetries,
+				MinBackoff: time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error creating HTTPClient: %+v", err)
+			}
+			_, err = hc.Write([]byte("{}\n"))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Write returned %v, expected error: %v", err, tc.wantErr)
+			}
+			if got := len(s.received()); got != tc.wantPosts {
+				t.Fatalf("server received %d requests, expected %d", got, tc.wantPosts)
+			}
+		})
+	}
+}
+
+func TestHTTPClientRetriesTimeouts(t *testing.T) {
+	s := newGELFHTTPServer(t)
+	defer s.Close()
+	s.delay = 200 * time.Millisecond
+
+	hc, err := graylog.NewHTTP(graylog.HTTPConfig{
+		URL:        s.URL,
+		Client:     &http.Client{Timeout: 50 * time.Millisecond},
+		MinBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating HTTPClient: %+v", err)
+	}
+	if _, err := hc.Write([]byte("{}\n")); err != nil {
+		t.Fatalf("Write returned unexpected error after a timeout: %+v", err)
+	}
+	if got := len(s.received()); got != 2 {
+		t.Fatalf("server received %d requests, expected 2", got)
+	}
+}
+
+func TestHTTPClientSendDoesNotBlockWrite(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer srv.Close()
+
+	hc, err := graylog.NewHTTP(graylog.HTTPConfig{URL: srv.URL, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error creating HTTPClient: %+v", err)
+	}
+	sent := make(chan error)
+	go func() {
+		hc.Write([]byte("{}\n"))
+		_, err := hc.Write([]byte("{}\n"))
+		sent <- err
+	}()
+	<-received
+
+	written := make(chan error)
+	go func() {
+		_, err := hc.Write([]byte("{}\n"))
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Errorf("Write returned unexpected error: %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Write waited for a send in progress")
+	}
+
+	closed := make(chan error)
+	go func() { closed <- hc.Close() }()
+	<-received
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned %v before the sends finished", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	if err := <-sent; err != nil {
+		t.Errorf("send returned unexpected error: %+v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("Close returned unexpected error: %+v", err)
+	}
+}
+
+func TestHTTPClientFlushInterval(t *testing.T) {
+	s := newGELFHTTPServer(t)
+	defer s.Close()
+
+	hc, err := graylog.NewHTTP(graylog.HTTPConfig{URL: s.URL, BatchSize: 10, FlushInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error creating HTTPClient: %+v", err)
+	}
+	defer hc.Close()
+	hc.Write([]byte("{}\n"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := s.received(); len(got) != 1 || got[0] != "{}" {
+		t.Fatalf("server received %q, expected one message from the periodic flush", got)
+	}
+}
+
+func TestHTTPClientWrite(t *testing.T) {
+	hc, err := graylog.NewHTTP(graylog.HTTPConfig{URL: "http://127.0.0.1:1/gelf"})
+	if err != nil {
+		t.Fatalf("unexpected error creating HTTPClient: %+v", err)
+	}
+	if n, err := hc.Write(nil); n != 0 || err != nil {
+		t.Errorf("Write(nil) = %d, %v, expected 0, nil", n, err)
+	}
+	p := []byte("{}")
+	if n, err := hc.Write(p); n != 0 || err != graylog.ErrMissingNewline {
+		t.Errorf("Write without newline = %d, %v, expected 0, %v", n, err, graylog.ErrMissingNewline)
+	}
+	if !bytes.Equal(p, []byte("{}")) {
+		t.Errorf("Write modified the input slice")
+	}
+	hc.Close()
+	if _, err := hc.Write([]byte("{}\n")); err != graylog.ErrClosed {
+		t.Errorf("Write after Close returned %v, expected %v", err, graylog.ErrClosed)
+	}
+
+	for _, c := range []graylog.HTTPConfig{
+		{},
+		{URL: "http://localhost/gelf", BatchSize: -1},
+		{URL: "http://localhost/gelf", Compress: true, CompressionLevel: 42},
+	} {
+		if _, err := graylog.NewHTTP(c); err == nil {
+			t.Errorf("NewHTTP(%+v) did not return an error", c)
+		}
+	}
+}
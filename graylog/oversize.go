@@ -0,0 +1,217 @@
+package graylog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// OversizePolicy selects how the Client handles messages that are too large
+// to send in 128 chunks.
+type OversizePolicy int
+
+const (
+	// OversizeError returns an error, dropping the message.
+	OversizeError OversizePolicy = iota
+	// OversizeTruncate shortens full_message and then the largest string
+	// fields until the message fits.
+	OversizeTruncate
+	// OversizeDropFields removes additional fields, largest first, until the
+	// message fits.
+	OversizeDropFields
+	// OversizeFallback writes the message unchanged to the Fallback Writer.
+	OversizeFallback
+)
+
+func (op OversizePolicy) String() string {
+	switch op {
+	case OversizeError:
+		return "error"
+	case OversizeTruncate:
+		return "truncate"
+	case OversizeDropFields:
+		return "drop fields"
+	case OversizeFallback:
+		return "fallback"
+	}
+	return fmt.Sprintf("OversizePolicy(%d)", int(op))
+}
+
+// OversizeEvent describes a message that was too large to send.
+type OversizeEvent struct {
+	// Policy is the policy that handled the message.
+	Policy OversizePolicy
+	// Size is the size of the message after compression, and MaxSize the
+	// largest size that can be sent.
+	Size    int
+	MaxSize int
+	// Fields lists the fields that were shortened or dropped.
+	Fields []string
+	// Err is the error returned by Write, if any.
+	Err error
+}
+
+type oversizeError struct {
+	size, max int
+}
+
+func (oe *oversizeError) Error() string {
+	return fmt.Sprintf("message exceeds maximum size, %d > %d", oe.size, oe.max)
+}
+
+// maxShrinkAttempts bounds how many times a message is shrunk, since the size
+// after compression can only be estimated.
+const maxShrinkAttempts = 8
+
+// writeOversize applies the oversize policy to p, which was too large to send.
+func (gl *Client) writeOversize(p []byte, oe *oversizeError) (int, error) {
+	event := OversizeEvent{Policy: gl.oversize, Size: oe.size, MaxSize: oe.max}
+	defer func() {
+		if gl.onOversize != nil {
+			gl.onOversize(event)
+		}
+	}()
+
+	switch gl.oversize {
+	case OversizeFallback:
+		if _, err := gl.fallback.Write(p); err != nil {
+			event.Err = fmt.Errorf("writing oversized message to fallback: %+v", err)
+			return 0, event.Err
+		}
+		return len(p), nil
+	case OversizeTruncate, OversizeDropFields:
+	default:
+		event.Err = oe
+		return 0, oe
+	}
+
+	var msg map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if err := dec.Decode(&msg); err != nil {
+		event.Err = fmt.Errorf("%v: cannot shrink invalid message: %+v", oe, err)
+		return 0, event.Err
+	}
+
+	shrunk := map[string]bool{}
+	size, compressed := len(bytes.TrimSpace(p)), oe.size
+	for attempt := 0; attempt < maxShrinkAttempts; attempt++ {
+		// aim below the limit, assuming the compression ratio holds
+		target := int(float64(size) * float64(oe.max) / float64(compressed) * 0.95)
+		if gl.oversize == OversizeTruncate {
+			truncateFields(msg, size-target, shrunk)
+		} else {
+			dropFields(msg, size-target, shrunk)
+		}
+
+		b, err := json.Marshal(msg)
+		if err != nil {
+			event.Err = err
+			return 0, err
+		}
+		event.Fields = sortedKeys(shrunk)
+		_, err = gl.send(b)
+		next, ok := err.(*oversizeError)
+		if !ok {
+			if err != nil {
+				event.Err = err
+				return 0, err
+			}
+			return len(p), nil
+		}
+		if len(b) >= size {
+			break // nothing left to shrink
+		}
+		size, compressed = len(b), next.size
+	}
+	event.Err = fmt.Errorf("%v: could not shrink message with policy %s", oe, gl.oversize)
+	return 0, event.Err
+}
+
+// truncateFields shortens full_message and then the largest strings, other
+// than version and host, by a total of excess bytes. The first rune of
+// short_message is kept, since graylog rejects messages without one.
+func truncateFields(msg map[string]interface{}, excess int, shrunk map[string]bool) {
+	for excess > 0 {
+		key, longest := "", 0
+		if s, ok := msg["full_message"].(string); ok && len(s) > 0 {
+			key, longest = "full_message", len(s)
+		} else {
+			for k, v := range msg {
+				s, ok := v.(string)
+				if !ok || k == "version" || k == "host" || len(s) <= longest || len(s) <= minLength(k, s) {
+					continue
+				}
+				key, longest = k, len(s)
+			}
+		}
+		if key == "" {
+			return
+		}
+
+		s := msg[key].(string)
+		n := len(s) - excess
+		if min := minLength(key, s); n < min {
+			n = min
+		}
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		msg[key] = s[:n]
+		excess -= len(s) - n
+		shrunk[key] = true
+	}
+}
+
+// minLength is the length of s, the value of field k, that truncateFields
+// keeps.
+func minLength(k, s string) int {
+	if k != "short_message" {
+		return 0
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return size
+}
+
+// dropFields removes additional fields, largest first, until their encoded
+// sizes add up to excess bytes.
+func dropFields(msg map[string]interface{}, excess int, shrunk map[string]bool) {
+	type field struct {
+		key  string
+		size int
+	}
+	var fields []field
+	for k, v := range msg {
+		if !strings.HasPrefix(k, "_") {
+			continue
+		}
+		b, _ := json.Marshal(v)
+		fields = append(fields, field{k, len(k) + len(b) + 4})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].size != fields[j].size {
+			return fields[i].size > fields[j].size
+		}
+		return fields[i].key < fields[j].key
+	})
+	for _, f := range fields {
+		if excess <= 0 {
+			return
+		}
+		delete(msg, f.key)
+		excess -= f.size
+		shrunk[f.key] = true
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
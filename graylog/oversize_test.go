@@ -0,0 +1,224 @@
+package graylog_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PermissionData/log/graylog"
+)
+
+// recordingPacketConn reassembles the chunks written to it into messages.
+type recordingPacketConn struct {
+	nopPacketConn
+	chunks   [][]byte
+	messages [][]byte
+}
+
+func (c *recordingPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if len(p) < 12 || p[0] != 0x1e || p[1] != 0x0f {
+		c.messages = append(c.messages, append([]byte(nil), p...))
+		return len(p), nil
+	}
+	c.chunks = append(c.chunks, append([]byte(nil), p[12:]...))
+	if int(p[10]) == int(p[11])-1 {
+		c.messages = append(c.messages, bytes.Join(c.chunks, nil))
+		c.chunks = nil
+	}
+	return len(p), nil
+}
+
+func randomString(n int) string {
+	b := make([]byte, n/2)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func TestWriteOversize(t *testing.T) {
+	testCases := []struct {
+		name        string
+		policy      graylog.OversizePolicy
+		compression graylog.Compression
+		input       map[string]interface{}
+		wantErr     bool
+		wantFields  []string
+		wantKept    []string
+	}{
+		{"error",
+			graylog.OversizeError,
+			graylog.CompressNone,
+			map[string]interface{}{"short_message": "hi", "full_message": strings.Repeat("a", 10000)},
+			true,
+			nil,
+			nil,
+		},
+		{"truncate full message",
+			graylog.OversizeTruncate,
+			graylog.CompressNone,
+			map[string]interface{}{"short_message": "hi", "full_message": strings.Repeat("a", 10000), "_user": "jdoe"},
+			false,
+			[]string{"full_message"},
+			[]string{"short_message", "_user"},
+		},
+		{"truncate largest fields",
+			graylog.OversizeTruncate,
+			graylog.CompressNone,
+			map[string]interface{}{"short_message": "hi", "_body": strings.Repeat("b", 6000), "_query": strings.Repeat("q", 5000), "_user": "jdoe"},
+			false,
+			[]string{"_body"},
+			[]string{"short_message", "_query", "_user"},
+		},
+		{"truncate short message to one rune",
+			graylog.OversizeTruncate,
+			graylog.CompressNone,
+			map[string]interface{}{"host": strings.Repeat("h", 7900), "short_message": strings.Repeat("é", 400)},
+			false,
+			[]string{"short_message"},
+			[]string{"host"},
+		},
+		{"truncate compressed",
+			graylog.OversizeTruncate,
+			graylog.CompressGzip,
+			map[string]interface{}{"short_message": "hi", "full_message": randomString(20000)},
+			false,
+			[]string{"full_message"},
+			[]string{"short_message"},
+		},
+		{"drop fields",
+			graylog.OversizeDropFields,
+			graylog.CompressNone,
+			map[string]interface{}{"short_message": "hi", "_body": strings.Repeat("b", 6000), "_query": strings.Repeat("q", 5000), "_user": "jdoe"},
+			false,
+			[]string{"_body"},
+			[]string{"short_message", "_query", "_user"},
+		},
+		{"drop fields not enough",
+			graylog.OversizeDropFields,
+			graylog.CompressNone,
+			map[string]interface{}{"short_message": "hi", "full_message": strings.Repeat("a", 10000), "_user": "jdoe"},
+			true,
+			[]string{"_user"},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &recordingPacketConn{}
+			var events []graylog.OversizeEvent
+			c, err := graylog.New(graylog.Config{
+				Compression:      tc.compression,
+				CompressionLevel: gzip.BestSpeed,
+				ChunkSize:        graylog.MinChunkSize, // 8192 bytes
+				Oversize:         tc.policy,
+				OnOversize:       func(e graylog.OversizeEvent) { events = append(events, e) },
+				ClientPacketConn: conn,
+			})
+			if err != nil {
+				t.Fatalf("error constructing New Client: %+v", err)
+			}
+
+			var buf bytes.Buffer
+			json.NewEncoder(&buf).Encode(tc.input)
+			p := buf.Bytes()
+			n, err := c.Write(p)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Write() = %d, %+v, expected err? %v", n, err, tc.wantErr)
+			}
+			if len(events) != 1 {
+				t.Fatalf("OnOversize called %d times, expected once", len(events))
+			}
+			e := events[0]
+			if e.Policy != tc.policy || e.MaxSize != 8192 || e.Size <= 8192 || (e.Err != nil) != tc.wantErr {
+				t.Errorf("OnOversize called with %+v", e)
+			}
+			if !reflect.DeepEqual(e.Fields, tc.wantFields) {
+				t.Errorf("OnOversize reported fields %v, expected %v", e.Fields, tc.wantFields)
+			}
+			if tc.wantErr {
+				return
+			}
+			if n != len(p) {
+				t.Errorf("Write() = %d, expected %d", n, len(p))
+			}
+
+			if len(conn.messages) != 1 {
+				t.Fatalf("sent %d messages, expected 1", len(conn.messages))
+			}
+			sent := conn.messages[0]
+			if tc.compression == graylog.CompressGzip {
+				zr, err := gzip.NewReader(bytes.NewReader(sent))
+				if err != nil {
+					t.Fatalf("failed to create gzip reader: %+v", err)
+				}
+				sent, _ = ioutil.ReadAll(zr)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(sent, &got); err != nil {
+				t.Fatalf("sent invalid JSON: %+v", err)
+			}
+			if s, _ := got["short_message"].(string); s == "" {
+				t.Errorf("sent short_message %q, expected it not to be empty", s)
+			}
+			for _, k := range tc.wantKept {
+				if !reflect.DeepEqual(got[k], tc.input[k]) {
+					t.Errorf("sent %s = %v, expected it unchanged", k, got[k])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteOversizeFallback(t *testing.T) {
+	var fallback bytes.Buffer
+	var events []graylog.OversizeEvent
+	c, err := graylog.New(graylog.Config{
+		Compression:      graylog.CompressNone,
+		ChunkSize:        graylog.MinChunkSize,
+		Oversize:         graylog.OversizeFallback,
+		Fallback:         &fallback,
+		OnOversize:       func(e graylog.OversizeEvent) { events = append(events, e) },
+		ClientPacketConn: &recordingPacketConn{},
+	})
+	if err != nil {
+		t.Fatalf("error constructing New Client: %+v", err)
+	}
+
+	p := []byte(fmt.Sprintf("{\"full_message\":\"%s\"}\n", strings.Repeat("a", 10000)))
+	if n, err := c.Write(p); n != len(p) || err != nil {
+		t.Fatalf("Write() = %d, %+v, expected %d, nil", n, err, len(p))
+	}
+	if !bytes.Equal(fallback.Bytes(), p) {
+		t.Errorf("Fallback received %d bytes, expected the %d byte message", fallback.Len(), len(p))
+	}
+	if len(events) != 1 || events[0].Policy != graylog.OversizeFallback || events[0].Policy.String() != "fallback" {
+		t.Errorf("OnOversize called with %+v, expected one fallback event", events)
+	}
+
+	small := []byte("{}\n")
+	c.Write(small)
+	if len(events) != 1 || fallback.Len() != len(p) {
+		t.Errorf("small message was handled as oversized")
+	}
+}
+
+func TestNew_Oversize(t *testing.T) {
+	for _, c := range []graylog.Config{
+		{ClientPacketConn: nopPacketConn{}, Oversize: -1},
+		{ClientPacketConn: nopPacketConn{}, Oversize: graylog.OversizeFallback + 1},
+		{ClientPacketConn: nopPacketConn{}, Oversize: graylog.OversizeFallback},
+	} {
+		if _, err := graylog.New(c); err == nil {
+			t.Errorf("New(%+v) did not return an error", c)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Chunked GELF packets start with these magic bytes, followed by an 8 byte
+// message ID, the sequence number, and the sequence count.
+const (
+	chunkMagicA     = 0x1e
+	chunkMagicB     = 0x0f
+	chunkHeaderSize = 12
+	maxChunkCount   = 128
+)
+
+func isChunk(packet []byte) bool {
+	return len(packet) >= 2 && packet[0] == chunkMagicA && packet[1] == chunkMagicB
+}
+
+type partial struct {
+	chunks [][]byte
+	// arrived marks the sequence numbers received, since chunks may be empty.
+	arrived  []bool
+	received int
+	started  time.Time
+}
+
+// assembler reassembles chunked messages by message ID, discarding messages
+// whose chunks do not all arrive within the timeout.
+type assembler struct {
+	timeout time.Duration
+
+	mux      sync.Mutex
+	partials map[[8]byte]*partial
+}
+
+func newAssembler(timeout time.Duration) *assembler {
+	return &assembler{timeout: timeout, partials: map[[8]byte]*partial{}}
+}
+
+// add records a chunk, returning the payload of the message once all of its
+// chunks have arrived.
+func (a *assembler) add(packet []byte, now time.Time) ([]byte, error) {
+	if len(packet) < chunkHeaderSize {
+		return nil, fmt.Errorf("chunk of %d bytes is shorter than its header", len(packet))
+	}
+	var id [8]byte
+	copy(id[:], packet[2:10])
+	seq, count := int(packet[10]), int(packet[11])
+	if count == 0 || count > maxChunkCount || seq >= count {
+		return nil, fmt.Errorf("chunk %d of %d is out of range", seq, count)
+	}
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	p, ok := a.partials[id]
+	if !ok {
+		p = &partial{chunks: make([][]byte, count), arrived: make([]bool, count), started: now}
+		a.partials[id] = p
+	}
+	if len(p.chunks) != count {
+		delete(a.partials, id)
+		return nil, fmt.Errorf("chunk count of %d does not match %d for message %x", count, len(p.chunks), id)
+	}
+	if !p.arrived[seq] {
+		p.chunks[seq] = append([]byte(nil), packet[chunkHeaderSize:]...)
+		p.arrived[seq] = true
+		p.received++
+	}
+	if p.received < count {
+		return nil, nil
+	}
+	delete(a.partials, id)
+	return bytes.Join(p.chunks, nil), nil
+}
+
+// expire discards incomplete messages older than the timeout, returning how
+// many were discarded.
+func (a *assembler) expire(now time.Time) int {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	expired := 0
+	for id, p := range a.partials {
+		if now.Sub(p.started) > a.timeout {
+			delete(a.partials, id)
+			expired++
+		}
+	}
+	return expired
+}
@@ -0,0 +1,45 @@
+package server_test
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/graylog"
+	"github.com/PermissionData/log/graylog/server"
+)
+
+func ExampleListenUDP() {
+	s, err := server.ListenUDP("127.0.0.1:0", server.Config{})
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	gw, err := graylog.New(graylog.Config{
+		ClientPacketConn: conn,
+		ServerAddr:       s.Addr(),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	logger := log.New(log.Config{
+		Threshold: log.InfoLevel,
+		Encoder:   graylog.NewEncoder(gw, "web-01"),
+	})
+	logger.Log(log.InfoLevel, log.Data{
+		"message": "user logged in",
+		"user":    "jdoe",
+	})
+
+	msg := <-s.Messages()
+	fmt.Println(msg.Host, msg.Level, msg.ShortMessage, msg.Fields["user"])
+	// Output:
+	// web-01 6 user logged in jdoe
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+)
+
+// Message is a decoded GELF message.
+type Message struct {
+	Version      string
+	Host         string
+	ShortMessage string
+	FullMessage  string
+	Timestamp    float64
+	Level        int
+	// Fields holds the additional fields, without their leading underscore.
+	Fields map[string]interface{}
+	// Raw is the JSON encoding of the message, after decompression.
+	Raw []byte
+	// Addr is the address of the sender.
+	Addr net.Addr
+}
+
+// Time returns the Timestamp as a time.Time.
+func (m *Message) Time() time.Time {
+	sec := int64(m.Timestamp)
+	return time.Unix(sec, int64((m.Timestamp-float64(sec))*1e9)).UTC()
+}
+
+// Decode decompresses a GELF payload, detecting gzip, zlib, or plain JSON,
+// and decodes the message.
+func Decode(payload []byte) (*Message, error) {
+	raw, err := decompress(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("decoding GELF message: %+v", err)
+	}
+
+	msg := &Message{Fields: map[string]interface{}{}, Raw: raw}
+	for k, v := range fields {
+		switch k {
+		case "version":
+			msg.Version = fmt.Sprint(v)
+		case "host":
+			msg.Host = fmt.Sprint(v)
+		case "short_message":
+			msg.ShortMessage = fmt.Sprint(v)
+		case "full_message":
+			msg.FullMessage = fmt.Sprint(v)
+		case "timestamp":
+			if n, ok := v.(json.Number); ok {
+				msg.Timestamp, _ = n.Float64()
+			}
+		case "level":
+			if n, ok := v.(json.Number); ok {
+				level, _ := n.Int64()
+				msg.Level = int(level)
+			}
+		default:
+			msg.Fields[strings.TrimPrefix(k, "_")] = v
+		}
+	}
+	return msg, nil
+}
+
+func decompress(payload []byte) ([]byte, error) {
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	switch {
+	case len(payload) >= 2 && payload[0] == 0x1f && payload[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(payload))
+	case len(payload) >= 2 && payload[0]&0x0f == 8 && (int(payload[0])<<8|int(payload[1]))%31 == 0:
+		r, err = zlib.NewReader(bytes.NewReader(payload))
+	default:
+		return payload, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decompressing GELF message: %+v", err)
+	}
+	defer r.Close()
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing GELF message: %+v", err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,327 @@
+// Package server receives GELF messages over UDP, TCP, and HTTP, reassembling
+// chunks and decompressing payloads, so that what a graylog client sends can
+// be inspected in tests or by local tools.
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/PermissionData/log"
+)
+
+// Config is used to set up a new Server
+type Config struct {
+	// ChunkTimeout is how long the chunks of a message may take to arrive
+	// before the message is discarded. The default is five seconds, as used
+	// by graylog.
+	ChunkTimeout time.Duration
+	// BufferSize is the capacity of the Messages channel. The default is 100.
+	// Receiving blocks while the channel is full.
+	BufferSize int
+	// ErrorHandler is called with packets and messages that cannot be
+	// decoded. The default is log.DefaultErrorHandler.
+	ErrorHandler func(error)
+}
+
+// Server receives GELF messages and delivers them on its Messages channel.
+type Server struct {
+	config   Config
+	messages chan *Message
+	chunks   *assembler
+	addr     net.Addr
+	closers  []func() error
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	connMux sync.Mutex
+	conns   map[net.Conn]bool
+}
+
+func newServer(c Config) *Server {
+	if c.ChunkTimeout <= 0 {
+		c.ChunkTimeout = 5 * time.Second
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 100
+	}
+	if c.ErrorHandler == nil {
+		c.ErrorHandler = log.DefaultErrorHandler
+	}
+	return &Server{
+		config:   c,
+		messages: make(chan *Message, c.BufferSize),
+		chunks:   newAssembler(c.ChunkTimeout),
+		done:     make(chan struct{}),
+		conns:    map[net.Conn]bool{},
+	}
+}
+
+// ListenUDP creates a Server receiving chunked or unchunked GELF messages,
+// compressed with gzip or zlib or not at all, on the UDP address.
+func ListenUDP(addr string, c Config) (*Server, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on udp %s: %+v", addr, err)
+	}
+	s := newServer(c)
+	s.addr = conn.LocalAddr()
+	s.closers = append(s.closers, conn.Close)
+
+	s.wg.Add(2)
+	go s.serveUDP(conn)
+	go s.expireChunks()
+	return s, nil
+}
+
+// ListenTCP creates a Server receiving null-terminated GELF messages on the
+// TCP address.
+func ListenTCP(addr string, c Config) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on tcp %s: %+v", addr, err)
+	}
+	return Serve(ln, c), nil
+}
+
+// Serve creates a Server receiving null-terminated GELF messages from
+// connections accepted by ln, such as a TLS listener.
+func Serve(ln net.Listener, c Config) *Server {
+	s := newServer(c)
+	s.addr = ln.Addr()
+	s.closers = append(s.closers, ln.Close)
+
+	s.wg.Add(1)
+	go s.serveTCP(ln)
+	return s
+}
+
+// ListenHTTP creates a Server receiving GELF messages posted to /gelf on the
+// TCP address, as the graylog GELF HTTP input does. A request may hold
+// several newline separated messages, and may be compressed.
+func ListenHTTP(addr string, c Config) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on tcp %s: %+v", addr, err)
+	}
+	s := newServer(c)
+	s.addr = ln.Addr()
+	srv := &http.Server{Handler: http.HandlerFunc(s.serveHTTP)}
+	s.closers = append(s.closers, srv.Close)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.config.ErrorHandler(fmt.Errorf("serving http: %+v", err))
+		}
+	}()
+	return s, nil
+}
+
+// Addr returns the address the Server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.addr
+}
+
+// Messages returns the channel on which received messages are delivered. It
+// is closed by Close.
+func (s *Server) Messages() <-chan *Message {
+	return s.messages
+}
+
+// Close stops receiving, closes any open connections, and closes the
+// Messages channel.
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		// connections and requests are only tracked in wg while done is open,
+		// so none are added once Close begins waiting
+		s.connMux.Lock()
+		close(s.done)
+		s.connMux.Unlock()
+		for _, fn := range s.closers {
+			if cerr := fn(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+		s.connMux.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.connMux.Unlock()
+
+		s.wg.Wait()
+		close(s.messages)
+	})
+	return err
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) {
+	defer s.wg.Done()
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.config.ErrorHandler(fmt.Errorf("reading udp packet: %+v", err))
+			continue
+		}
+
+		payload := buf[:n]
+		if isChunk(payload) {
+			if payload, err = s.chunks.add(payload, time.Now()); err != nil {
+				s.config.ErrorHandler(err)
+				continue
+			}
+			if payload == nil {
+				continue
+			}
+		} else {
+			// buf is reused for the next packet, and an uncompressed
+			// payload becomes the Raw of the Message
+			payload = append([]byte(nil), payload...)
+		}
+		s.receive(payload, addr)
+	}
+}
+
+func (s *Server) expireChunks() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.config.ChunkTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			if n := s.chunks.expire(now); n > 0 {
+				s.config.ErrorHandler(fmt.Errorf("discarded %d incomplete chunked messages", n))
+			}
+		}
+	}
+}
+
+func (s *Server) serveTCP(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.config.ErrorHandler(fmt.Errorf("accepting tcp connection: %+v", err))
+			return
+		}
+
+		s.connMux.Lock()
+		select {
+		case <-s.done:
+			s.connMux.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.connMux.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.connMux.Lock()
+		delete(s.conns, conn)
+		s.connMux.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		frame, err := r.ReadBytes(0)
+		if err == nil {
+			frame = frame[:len(frame)-1]
+		}
+		if len(frame) > 0 {
+			s.receive(frame, conn.RemoteAddr())
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// http.Server.Close does not wait for running handlers, so each request
+	// is tracked until it has delivered its messages
+	s.connMux.Lock()
+	select {
+	case <-s.done:
+		s.connMux.Unlock()
+		http.Error(w, "server is closed", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	s.wg.Add(1)
+	s.connMux.Unlock()
+	defer s.wg.Done()
+
+	if r.URL.Path != "/gelf" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	raw, err := decompress(body)
+	if err != nil {
+		s.config.ErrorHandler(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	addr, _ := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			s.receive(line, addr)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// receive decodes a GELF payload and delivers it on the Messages channel.
+func (s *Server) receive(payload []byte, addr net.Addr) {
+	msg, err := Decode(payload)
+	if err != nil {
+		s.config.ErrorHandler(err)
+		return
+	}
+	msg.Addr = addr
+	select {
+	case s.messages <- msg:
+	case <-s.done:
+	}
+}
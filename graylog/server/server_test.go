@@ -0,0 +1,394 @@
+package server_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/graylog"
+	"github.com/PermissionData/log/graylog/server"
+)
+
+func next(t *testing.T, s *server.Server) *server.Message {
+	t.Helper()
+	select {
+	case msg := <-s.Messages():
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a message")
+	}
+	return nil
+}
+
+func TestListenUDP(t *testing.T) {
+	testCases := []struct {
+		name        string
+		compression graylog.Compression
+		size        int
+	}{
+		{"gzip", graylog.CompressGzip, 10},
+		{"gzip chunked", graylog.CompressGzip, 100000},
+		{"zlib", graylog.CompressZlib, 10},
+		{"zlib chunked", graylog.CompressZlib, 100000},
+		{"uncompressed", graylog.CompressNone, 10},
+		{"uncompressed chunked", graylog.CompressNone, 10000},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := server.ListenUDP("127.0.0.1:0", server.Config{})
+			if err != nil {
+				t.Fatalf("unexpected error listening: %+v", err)
+			}
+			defer s.Close()
+
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("unexpected error creating client connection: %+v", err)
+			}
+			defer conn.Close()
+			gw, err := graylog.New(graylog.Config{
+				Compression:      tc.compression,
+				CompressionLevel: gzip.BestSpeed,
+				ServerAddr:       s.Addr(),
+				ClientPacketConn: conn,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error creating Client: %+v", err)
+			}
+
+			// random enough to not compress into a single chunk
+			body := strings.Repeat("pop ", tc.size/4)
+			if tc.size > 1000 {
+				var b strings.Builder
+				for i := 0; b.Len() < tc.size; i++ {
+					b.WriteString(time.Duration(i * 7919).String())
+				}
+				body = b.String()
+			}
+			enc := graylog.NewEncoder(gw, "web-01")
+			if err := enc.Encode(log.Data{
+				log.TimestampKey: "2017-01-02T03:04:05.000Z",
+				log.LevelKey:     log.ErrorLevel,
+				log.MessageKey:   "hello",
+				log.StackKey:     "goroutine 1",
+				"user":           "jdoe",
+				"body":           body,
+				"pi":             3.14,
+			}); err != nil {
+				t.Fatalf("Encode returned unexpected error: %+v", err)
+			}
+
+			msg := next(t, s)
+			if msg.Version != "1.1" || msg.Host != "web-01" || msg.ShortMessage != "hello" || msg.FullMessage != "goroutine 1" || msg.Level != 3 {
+				t.Errorf("received %+v", msg)
+			}
+			if want := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC); !msg.Time().Equal(want) {
+				t.Errorf("received timestamp %v, expected %v", msg.Time(), want)
+			}
+			if msg.Fields["user"] != "jdoe" || msg.Fields["body"] != body || msg.Fields["pi"] != json.Number("3.14") {
+				t.Errorf("received fields %v", msg.Fields)
+			}
+			if msg.Addr.String() != conn.LocalAddr().String() {
+				t.Errorf("received from %v, expected %v", msg.Addr, conn.LocalAddr())
+			}
+		})
+	}
+}
+
+func TestListenUDPKeepsRaw(t *testing.T) {
+	s, err := server.ListenUDP("127.0.0.1:0", server.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error listening: %+v", err)
+	}
+	defer s.Close()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error creating client connection: %+v", err)
+	}
+	defer conn.Close()
+	gw, err := graylog.New(graylog.Config{
+		Compression:      graylog.CompressNone,
+		ServerAddr:       s.Addr(),
+		ClientPacketConn: conn,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating Client: %+v", err)
+	}
+
+	want := []string{
+		`{"short_message":"1st message ` + strings.Repeat("a", 100) + `"}`,
+		`{"short_message":"2nd"}`,
+	}
+	for _, raw := range want {
+		if _, err := gw.Write([]byte(raw + "\n")); err != nil {
+			t.Fatalf("Write returned unexpected error: %+v", err)
+		}
+	}
+	first, second := next(t, s), next(t, s)
+	for i, msg := range []*server.Message{first, second} {
+		if string(msg.Raw) != want[i] {
+			t.Errorf("message %d has Raw %s, expected %s", i+1, msg.Raw, want[i])
+		}
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	s, err := server.ListenTCP("127.0.0.1:0", server.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error listening: %+v", err)
+	}
+	defer s.Close()
+
+	tc, err := graylog.NewTCP(graylog.TCPConfig{ServerAddr: s.Addr().String()})
+	if err != nil {
+		t.Fatalf("unexpected error creating TCPClient: %+v", err)
+	}
+	defer tc.Close()
+
+	large := strings.Repeat("a", 500000)
+	enc := graylog.NewEncoder(tc, "web-01")
+	for _, m := range []string{"first", large} {
+		if err := enc.Encode(log.Data{log.MessageKey: m}); err != nil {
+			t.Fatalf("Encode returned unexpected error: %+v", err)
+		}
+		if msg := next(t, s); msg.ShortMessage != m {
+			t.Fatalf("received %d byte message, expected %d bytes", len(msg.ShortMessage), len(m))
+		}
+	}
+}
+
+func chunk(id string, seq, count int, payload []byte) []byte {
+	return append(append([]byte{0x1e, 0x0f}, append([]byte(id), byte(seq), byte(count))...), payload...)
+}
+
+func TestChunkReassembly(t *testing.T) {
+	errs := make(chan error, 10)
+	s, err := server.ListenUDP("127.0.0.1:0", server.Config{
+		ChunkTimeout: 50 * time.Millisecond,
+		ErrorHandler: func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error listening: %+v", err)
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %+v", err)
+	}
+	defer conn.Close()
+
+	payload := []byte(`{"version":"1.1","host":"h","short_message":"out of order"}`)
+	parts := [][]byte{payload[:20], payload[20:40], payload[40:]}
+	conn.Write(chunk("AAAAAAAA", 2, 3, parts[2]))
+	conn.Write(chunk("BBBBBBBB", 0, 2, []byte(`{"short_message":`))) // never completed
+	conn.Write(chunk("AAAAAAAA", 0, 3, parts[0]))
+	conn.Write(chunk("AAAAAAAA", 0, 3, parts[0])) // duplicate
+	conn.Write(chunk("AAAAAAAA", 1, 3, parts[1]))
+
+	if msg := next(t, s); msg.ShortMessage != "out of order" {
+		t.Fatalf("received %q, expected the reassembled message", msg.ShortMessage)
+	}
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "discarded 1 incomplete") {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("incomplete message was not discarded")
+	}
+
+	conn.Write(chunk("CCCCCCCC", 3, 2, nil))
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "out of range") {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("invalid chunk was not reported")
+	}
+
+	conn.Write(chunk("DDDDDDDD", 0, 2, nil))
+	conn.Write(chunk("DDDDDDDD", 0, 2, nil)) // duplicate empty chunk
+	conn.Write(chunk("DDDDDDDD", 1, 2, payload))
+	if msg := next(t, s); msg.ShortMessage != "out of order" {
+		t.Fatalf("received %q, expected the message after an empty chunk", msg.ShortMessage)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	plain := []byte(`{"version":"1.1","host":"h","short_message":"hi","level":6,"timestamp":1483326245.5,"_user":"jdoe"}`)
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(plain)
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write(plain)
+	zw.Close()
+
+	for name, payload := range map[string][]byte{"plain": plain, "gzip": gz.Bytes(), "zlib": zl.Bytes()} {
+		msg, err := server.Decode(payload)
+		if err != nil {
+			t.Fatalf("Decode(%s) returned unexpected error: %+v", name, err)
+		}
+		if msg.ShortMessage != "hi" || msg.Level != 6 || msg.Fields["user"] != "jdoe" || !bytes.Equal(msg.Raw, plain) {
+			t.Errorf("Decode(%s) = %+v", name, msg)
+		}
+		if want := time.Date(2017, 1, 2, 3, 4, 5, 5e8, time.UTC); !msg.Time().Equal(want) {
+			t.Errorf("Decode(%s) has time %v, expected %v", name, msg.Time(), want)
+		}
+	}
+
+	for _, payload := range [][]byte{[]byte("not json"), {0x1f, 0x8b, 0}, {0x78, 0x9c, 0}} {
+		if _, err := server.Decode(payload); err == nil {
+			t.Errorf("Decode(%q) did not return an error", payload)
+		}
+	}
+}
+
+func TestCloseClosesMessages(t *testing.T) {
+	s, err := server.ListenTCP("127.0.0.1:0", server.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error listening: %+v", err)
+	}
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %+v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte(`{"short_message":"hi"}` + "\x00"))
+	next(t, s)
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned unexpected error: %+v", err)
+	}
+	if _, ok := <-s.Messages(); ok {
+		t.Fatalf("Messages channel is open after Close")
+	}
+	s.Close()
+}
+
+func TestListenHTTP(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config graylog.HTTPConfig
+	}{
+		{"single", graylog.HTTPConfig{}},
+		{"batched", graylog.HTTPConfig{BatchSize: 2}},
+		{"compressed", graylog.HTTPConfig{BatchSize: 2, Compress: true}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := server.ListenHTTP("127.0.0.1:0", server.Config{})
+			if err != nil {
+				t.Fatalf("unexpected error listening: %+v", err)
+			}
+			defer s.Close()
+
+			tc.config.URL = "http://" + s.Addr().String() + "/gelf"
+			hc, err := graylog.NewHTTP(tc.config)
+			if err != nil {
+				t.Fatalf("unexpected error creating HTTPClient: %+v", err)
+			}
+			enc := graylog.NewEncoder(hc, "web-01")
+			for _, m := range []string{"first", "second"} {
+				if err := enc.Encode(log.Data{log.MessageKey: m}); err != nil {
+					t.Fatalf("Encode returned unexpected error: %+v", err)
+				}
+			}
+			for _, m := range []string{"first", "second"} {
+				if msg := next(t, s); msg.ShortMessage != m || msg.Host != "web-01" {
+					t.Fatalf("received %+v, expected %s", msg, m)
+				}
+			}
+		})
+	}
+}
+
+func TestListenHTTPRejects(t *testing.T) {
+	s, err := server.ListenHTTP("127.0.0.1:0", server.Config{ErrorHandler: func(error) {}})
+	if err != nil {
+		t.Fatalf("unexpected error listening: %+v", err)
+	}
+	defer s.Close()
+	url := "http://" + s.Addr().String()
+
+	resp, err := http.Get(url + "/gelf")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /gelf returned %s, expected 405", resp.Status)
+	}
+	resp, err = http.Post(url+"/other", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("POST /other returned %s, expected 404", resp.Status)
+	}
+	resp, err = http.Post(url+"/gelf", "application/json", bytes.NewReader([]byte{0x1f, 0x8b, 0}))
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /gelf with invalid gzip returned %s, expected 400", resp.Status)
+	}
+}
+
+func TestListenHTTPClose(t *testing.T) {
+	s, err := server.ListenHTTP("127.0.0.1:0", server.Config{ErrorHandler: func(error) {}})
+	if err != nil {
+		t.Fatalf("unexpected error listening: %+v", err)
+	}
+	url := "http://" + s.Addr().String() + "/gelf"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				resp, err := http.Post(url, "application/json", strings.NewReader(`{"short_message":"hi"}`))
+				if err != nil {
+					return
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusAccepted {
+					return
+				}
+			}
+		}()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for range s.Messages() {
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned unexpected error: %+v", err)
+	}
+	<-drained
+	wg.Wait()
+}
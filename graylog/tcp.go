@@ -0,0 +1,193 @@
+package graylog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// TCPClient is a Writer for graylog over TCP. Each message is sent
+// uncompressed and terminated by a null byte, so unlike the UDP Client there
+// is no limit on the size of a message.
+type TCPClient struct {
+	config TCPConfig
+
+	mux      sync.Mutex
+	conn     net.Conn
+	failures int
+	retryAt  time.Time
+	frame    []byte
+	closed   bool
+}
+
+// TCPConfig is used to set up a new TCPClient
+type TCPConfig struct {
+	// ServerAddr is the host:port of the graylog GELF TCP input.
+	ServerAddr string
+	// TLSConfig enables TLS when set. The ServerName used to verify the
+	// server defaults to the host of ServerAddr. Client certificates can be
+	// reloaded from disk by setting GetClientCertificate to a CertReloader.
+	TLSConfig *tls.Config
+	// Dial opens connections to the server, before any TLS handshake. The
+	// default is net.DialTimeout with DialTimeout.
+	Dial func(network, addr string) (net.Conn, error)
+	// DialTimeout limits how long connecting may take. The default is five
+	// seconds.
+	DialTimeout time.Duration
+	// WriteTimeout limits how long sending a message may take. The default
+	// is five seconds.
+	WriteTimeout time.Duration
+	// MinBackoff and MaxBackoff bound the delay before reconnecting after a
+	// failure, which doubles with each consecutive failure. The defaults are
+	// 100 milliseconds and 30 seconds.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// ErrClosed is returned when writing to a closed client.
+var ErrClosed = errors.New("client is closed")
+
+// NewTCP creates a TCPClient with the Config provided. The connection is
+// opened by the first Write.
+func NewTCP(c TCPConfig) (*TCPClient, error) {
+	if c.ServerAddr == "" {
+		return nil, fmt.Errorf("cannot create new TCPClient without a server address")
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = 5 * time.Second
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 5 * time.Second
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 100 * time.Millisecond
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = 30 * time.Second
+	}
+	if c.Dial == nil {
+		timeout := c.DialTimeout
+		c.Dial = func(network, addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, timeout)
+		}
+	}
+	if c.TLSConfig != nil {
+		c.Dial = dialTLS(c.Dial, c.TLSConfig, c.ServerAddr, c.DialTimeout)
+	}
+	return &TCPClient{config: c}, nil
+}
+
+// dialTLS wraps dial to complete a TLS handshake on each new connection.
+func dialTLS(dial func(network, addr string) (net.Conn, error), config *tls.Config, serverAddr string, timeout time.Duration) func(network, addr string) (net.Conn, error) {
+	config = config.Clone()
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(serverAddr)
+		if err != nil {
+			host = serverAddr
+		}
+		config.ServerName = host
+	}
+	return func(network, addr string) (net.Conn, error) {
+		conn, err := dial(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, config)
+		tlsConn.SetDeadline(time.Now().Add(timeout))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("tls handshake: %+v", err)
+		}
+		tlsConn.SetDeadline(time.Time{})
+		return tlsConn, nil
+	}
+}
+
+// Write sends the contents of a byte slice as a single null-terminated GELF
+// message, reconnecting if the connection has failed. Like the UDP Client, p
+// must end with a newline.
+func (tc *TCPClient) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !bytes.HasSuffix(p, []byte("\n")) {
+		return 0, ErrMissingNewline
+	}
+
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+	if tc.closed {
+		return 0, ErrClosed
+	}
+
+	tc.frame = append(tc.frame[:0], bytes.TrimFunc(p, unicode.IsSpace)...)
+	tc.frame = append(tc.frame, 0)
+
+	reused := tc.conn != nil
+	if !reused {
+		if wait := time.Until(tc.retryAt); wait > 0 {
+			return 0, fmt.Errorf("reconnecting to %s in %v", tc.config.ServerAddr, wait.Round(time.Millisecond))
+		}
+	}
+
+	// a connection that has been idle may have been closed by the server, so
+	// a failed write is retried once on a new connection
+	err := tc.send()
+	if err != nil && reused {
+		err = tc.send()
+	}
+	if err != nil {
+		tc.fail()
+		return 0, err
+	}
+	tc.failures = 0
+	return len(p), nil
+}
+
+// send writes the frame, connecting first if needed. tc.mux must be held.
+func (tc *TCPClient) send() error {
+	if tc.conn == nil {
+		conn, err := tc.config.Dial("tcp", tc.config.ServerAddr)
+		if err != nil {
+			return fmt.Errorf("connecting to %s: %+v", tc.config.ServerAddr, err)
+		}
+		tc.conn = conn
+	}
+
+	tc.conn.SetWriteDeadline(time.Now().Add(tc.config.WriteTimeout))
+	if _, err := tc.conn.Write(tc.frame); err != nil {
+		tc.conn.Close()
+		tc.conn = nil
+		return fmt.Errorf("writing to tcp connection: %+v", err)
+	}
+	return nil
+}
+
+// fail records a failure, delaying the next connection attempt.
+func (tc *TCPClient) fail() {
+	backoff := tc.config.MinBackoff << uint(tc.failures)
+	if backoff > tc.config.MaxBackoff || backoff <= 0 {
+		backoff = tc.config.MaxBackoff
+	} else {
+		tc.failures++
+	}
+	tc.retryAt = time.Now().Add(backoff)
+}
+
+// Close closes the connection. Writes after Close return ErrClosed.
+func (tc *TCPClient) Close() error {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+	tc.closed = true
+	if tc.conn == nil {
+		return nil
+	}
+	err := tc.conn.Close()
+	tc.conn = nil
+	return err
+}
@@ -0,0 +1,225 @@
+package graylog_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log/graylog"
+)
+
+// tcpServer accepts connections and collects null-terminated frames.
+type tcpServer struct {
+	ln     net.Listener
+	frames chan string
+	wg     sync.WaitGroup
+}
+
+func newTCPServer(t *testing.T) *tcpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error listening: %+v", err)
+	}
+	return serveTCP(ln)
+}
+
+func serveTCP(ln net.Listener) *tcpServer {
+	s := &tcpServer{ln: ln, frames: make(chan string, 100)}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					frame, err := r.ReadString(0)
+					if err != nil {
+						return
+					}
+					s.frames <- strings.TrimSuffix(frame, "\x00")
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *tcpServer) Addr() string { return s.ln.Addr().String() }
+
+func (s *tcpServer) Close() {
+	s.ln.Close()
+}
+
+func (s *tcpServer) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case frame := <-s.frames:
+		return frame
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a frame")
+	}
+	return ""
+}
+
+func TestTCPClientWritesFrames(t *testing.T) {
+	s := newTCPServer(t)
+	defer s.Close()
+
+	tc, err := graylog.NewTCP(graylog.TCPConfig{ServerAddr: s.Addr()})
+	if err != nil {
+		t.Fatalf("unexpected error creating TCPClient: %+v", err)
+	}
+	defer tc.Close()
+
+	large := strings.Repeat("a", 200000) // far beyond the UDP limit
+	enc := json.NewEncoder(tc)
+	for _, msg := range []string{"hello", large} {
+		if err := enc.Encode(map[string]string{"short_message": msg}); err != nil {
+			t.Fatalf("Encode returned unexpected error: %+v", err)
+		}
+		want := fmt.Sprintf(`{"short_message":"%s"}`, msg)
+		if got := s.next(t); got != want {
+			t.Fatalf("server received a %d byte frame, expected %d bytes", len(got), len(want))
+		}
+	}
+}
+
+func TestTCPClientWrite(t *testing.T) {
+	tc, err := graylog.NewTCP(graylog.TCPConfig{ServerAddr: "127.0.0.1:1"})
+	if err != nil {
+		t.Fatalf("unexpected error creating TCPClient: %+v", err)
+	}
+
+	var w io.Writer = tc
+	if n, err := w.Write(nil); n != 0 || err != nil {
+		t.Errorf("Write(nil) = %d, %v, expected 0, nil", n, err)
+	}
+	if n, err := w.Write([]byte("{}")); n != 0 || err != graylog.ErrMissingNewline {
+		t.Errorf("Write without newline = %d, %v, expected 0, %v", n, err, graylog.ErrMissingNewline)
+	}
+
+	tc.Close()
+	if _, err := w.Write([]byte("{}\n")); err != graylog.ErrClosed {
+		t.Errorf("Write after Close returned %v, expected %v", err, graylog.ErrClosed)
+	}
+
+	if _, err := graylog.NewTCP(graylog.TCPConfig{}); err == nil {
+		t.Errorf("NewTCP without an address did not return an error")
+	}
+}
+
+// flakyConn fails its first write.
+type flakyConn struct {
+	net.Conn
+	failed bool
+}
+
+func (c *flakyConn) Write(p []byte) (int, error) {
+	if !c.failed {
+		c.failed = true
+		return 0, errors.New("connection reset by peer")
+	}
+	return c.Conn.Write(p)
+}
+
+func TestTCPClientReconnects(t *testing.T) {
+	s := newTCPServer(t)
+	defer s.Close()
+
+	var dials, failDials int
+	tc, err := graylog.NewTCP(graylog.TCPConfig{
+		ServerAddr: s.Addr(),
+		MinBackoff: 20 * time.Millisecond,
+		Dial: func(network, addr string) (net.Conn, error) {
+			dials++
+			if failDials > 0 {
+				failDials--
+				return nil, errors.New("connection refused")
+			}
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &flakyConn{Conn: conn, failed: dials > 1}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating TCPClient: %+v", err)
+	}
+	defer tc.Close()
+
+	// the first connection fails its write, which is not retried since the
+	// connection was new
+	if _, err := tc.Write([]byte("{\"n\":1}\n")); err == nil {
+		t.Fatalf("Write on a failing connection did not return an error")
+	}
+	if _, err := tc.Write([]byte("{\"n\":2}\n")); err == nil || !strings.Contains(err.Error(), "reconnecting") {
+		t.Fatalf("Write during backoff returned %v, expected a reconnecting error", err)
+	}
+	if dials != 1 {
+		t.Fatalf("dialed %d times during backoff, expected 1", dials)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	failDials = 1
+	if _, err := tc.Write([]byte("{\"n\":3}\n")); err == nil {
+		t.Fatalf("Write with a failing dial did not return an error")
+	}
+	time.Sleep(50 * time.Millisecond) // backoff has doubled
+	if _, err := tc.Write([]byte("{\"n\":4}\n")); err != nil {
+		t.Fatalf("Write after backoff returned unexpected error: %+v", err)
+	}
+	if got := s.next(t); got != `{"n":4}` {
+		t.Fatalf("server received %s, expected the fourth message", got)
+	}
+}
+
+func TestTCPClientRetriesStaleConnection(t *testing.T) {
+	s := newTCPServer(t)
+	defer s.Close()
+
+	var conns []*flakyConn
+	tc, err := graylog.NewTCP(graylog.TCPConfig{
+		ServerAddr: s.Addr(),
+		Dial: func(network, addr string) (net.Conn, error) {
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			c := &flakyConn{Conn: conn, failed: true}
+			conns = append(conns, c)
+			return c, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating TCPClient: %+v", err)
+	}
+	defer tc.Close()
+
+	if _, err := tc.Write([]byte("{\"n\":1}\n")); err != nil {
+		t.Fatalf("Write returned unexpected error: %+v", err)
+	}
+	s.next(t)
+
+	conns[0].failed = false // the server has gone away
+	if _, err := tc.Write([]byte("{\"n\":2}\n")); err != nil {
+		t.Fatalf("Write on a stale connection returned unexpected error: %+v", err)
+	}
+	if got := s.next(t); got != `{"n":2}` || len(conns) != 2 {
+		t.Fatalf("server received %s over %d connections, expected the second message over a new connection", got, len(conns))
+	}
+}
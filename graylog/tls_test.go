@@ -0,0 +1,263 @@
+package graylog_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log/graylog"
+)
+
+// testCA issues certificates for TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %+v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error creating CA: %+v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue returns PEM encoded certificate and key for name, valid for serving
+// localhost or as a client.
+func (ca *testCA) issue(t *testing.T, name string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %+v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("unexpected error issuing certificate: %+v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling key: %+v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) keyPair(t *testing.T, name string) tls.Certificate {
+	certPEM, keyPEM := ca.issue(t, name)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("unexpected error loading key pair: %+v", err)
+	}
+	return cert
+}
+
+// writeKeyPair writes a certificate for name to the files, setting their
+// modification time to mtime.
+func (ca *testCA) writeKeyPair(t *testing.T, name, certFile, keyFile string, mtime time.Time) {
+	certPEM, keyPEM := ca.issue(t, name)
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatalf("unexpected error writing certificate: %+v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("unexpected error writing key: %+v", err)
+	}
+	os.Chtimes(certFile, mtime, mtime)
+	os.Chtimes(keyFile, mtime, mtime)
+}
+
+// newTLSServer serves TLS, requiring client certificates issued by ca, and
+// reports the common name of each client.
+func newTLSServer(t *testing.T, ca *testCA, clients chan<- string) *tcpServer {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{ca.keyPair(t, "server")},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		VerifyPeerCertificate: func(_ [][]byte, chains [][]*x509.Certificate) error {
+			clients <- chains[0][0].Subject.CommonName
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error listening: %+v", err)
+	}
+	return serveTCP(ln)
+}
+
+func TestTCPClientTLS(t *testing.T) {
+	ca := newTestCA(t)
+	clients := make(chan string, 10)
+	s := newTLSServer(t, ca, clients)
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "graylog-tls")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	ca.writeKeyPair(t, "first", certFile, keyFile, time.Now().Add(-time.Minute))
+
+	reloader, err := graylog.NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("unexpected error creating CertReloader: %+v", err)
+	}
+	tc, err := graylog.NewTCP(graylog.TCPConfig{
+		ServerAddr: s.Addr(),
+		TLSConfig: &tls.Config{
+			RootCAs:              ca.pool,
+			GetClientCertificate: reloader.GetClientCertificate,
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating TCPClient: %+v", err)
+	}
+
+	if _, err := tc.Write([]byte("{\"n\":1}\n")); err != nil {
+		t.Fatalf("Write returned unexpected error: %+v", err)
+	}
+	if got := s.next(t); got != `{"n":1}` {
+		t.Fatalf("server received %s, expected the first message", got)
+	}
+	if name := <-clients; name != "first" {
+		t.Fatalf("server saw client %q, expected first", name)
+	}
+	tc.Close()
+
+	// a rotated certificate is used for the next connection
+	ca.writeKeyPair(t, "second", certFile, keyFile, time.Now())
+	tc, err = graylog.NewTCP(graylog.TCPConfig{
+		ServerAddr: s.Addr(),
+		TLSConfig: &tls.Config{
+			RootCAs:              ca.pool,
+			GetClientCertificate: reloader.GetClientCertificate,
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating TCPClient: %+v", err)
+	}
+	defer tc.Close()
+	if _, err := tc.Write([]byte("{\"n\":2}\n")); err != nil {
+		t.Fatalf("Write returned unexpected error: %+v", err)
+	}
+	s.next(t)
+	if name := <-clients; name != "second" {
+		t.Fatalf("server saw client %q, expected the reloaded certificate", name)
+	}
+}
+
+func TestTCPClientTLSVerifiesServer(t *testing.T) {
+	ca := newTestCA(t)
+	s := newTLSServer(t, ca, make(chan string, 10))
+	defer s.Close()
+	client := ca.keyPair(t, "client")
+
+	testCases := []struct {
+		name    string
+		config  *tls.Config
+		wantErr string
+	}{
+		{"unknown authority",
+			&tls.Config{Certificates: []tls.Certificate{client}},
+			"unknown authority",
+		},
+		{"wrong server name",
+			&tls.Config{RootCAs: ca.pool, ServerName: "graylog.example.com", Certificates: []tls.Certificate{client}},
+			"graylog.example.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := graylog.NewTCP(graylog.TCPConfig{ServerAddr: s.Addr(), TLSConfig: tc.config})
+			if err != nil {
+				t.Fatalf("unexpected error creating TCPClient: %+v", err)
+			}
+			defer client.Close()
+			if _, err := client.Write([]byte("{}\n")); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Write returned %v, expected an error mentioning %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	ca := newTestCA(t)
+	dir, err := ioutil.TempDir("", "graylog-tls")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+
+	if _, err := graylog.NewCertReloader(certFile, keyFile); err == nil {
+		t.Fatalf("NewCertReloader with missing files did not return an error")
+	}
+
+	ca.writeKeyPair(t, "first", certFile, keyFile, time.Now().Add(-time.Minute))
+	cr, err := graylog.NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("unexpected error creating CertReloader: %+v", err)
+	}
+	first, _ := cr.GetClientCertificate(nil)
+	if again, _ := cr.GetClientCertificate(nil); again != first {
+		t.Errorf("GetClientCertificate loaded an unmodified certificate again")
+	}
+
+	ioutil.WriteFile(certFile, []byte("not a certificate"), 0600)
+	if cert, err := cr.GetClientCertificate(nil); cert != first || err != nil {
+		t.Errorf("GetClientCertificate with an invalid file returned %v, %v, expected the previous certificate", cert, err)
+	}
+	if _, err := cr.Certificate(); err == nil {
+		t.Errorf("Certificate with an invalid file did not return an error")
+	}
+
+	ca.writeKeyPair(t, "second", certFile, keyFile, time.Now())
+	second, err := cr.GetCertificate(nil)
+	if err != nil || second == first {
+		t.Fatalf("GetCertificate did not reload the modified certificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(second.Certificate[0])
+	if leaf.Subject.CommonName != "second" {
+		t.Errorf("GetCertificate returned %q, expected second", leaf.Subject.CommonName)
+	}
+}
@@ -3,6 +3,7 @@ package graylog
 import (
 	"bytes"
 	"compress/gzip"
+	"compress/zlib"
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
@@ -10,7 +11,11 @@ import (
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
+
+	"github.com/PermissionData/log"
 )
 
 // Client is a Writer for graylog over UDP or other Packet Connection
@@ -18,6 +23,12 @@ type Client struct {
 	instanceID [4]byte
 	addr       net.Addr
 	conn       net.PacketConn
+	chunkSize  int
+	oversize   OversizePolicy
+	fallback   io.Writer
+	onOversize func(OversizeEvent)
+	queue      *asyncQueue
+	closed     int32
 
 	countMux     sync.Mutex
 	messageCount uint32
@@ -27,17 +38,145 @@ type Client struct {
 
 // Config is used to set up a new Client
 type Config struct {
+	// Compression selects how messages are compressed. The default is gzip.
+	Compression Compression
+	// CompressionLevel is used by gzip and zlib compression.
 	CompressionLevel int
+	// ChunkSize is the largest payload sent in a single packet, not counting
+	// the chunk header. The default is ChunkSizeWAN, and ChunkSizeLAN suits
+	// networks with jumbo frames. Messages are limited to 128 chunks.
+	ChunkSize int
+	// Oversize selects how messages too large to send are handled. The
+	// default is to return an error.
+	Oversize OversizePolicy
+	// Fallback receives messages that are too large to send when Oversize is
+	// OversizeFallback, such as a TCPClient.
+	Fallback io.Writer
+	// OnOversize is called whenever a message is too large to send, with the
+	// policy that handled it.
+	OnOversize func(OversizeEvent)
+	// QueueSize enables asynchronous sending when positive. Writes copy the
+	// message into a queue of this many messages, and Workers compress and
+	// send them in the background.
+	QueueSize int
+	// Workers is the number of goroutines sending queued messages. The
+	// default is 1.
+	Workers int
+	// QueueFull selects what Write does when the queue is full. The default
+	// is to wait for room.
+	QueueFull QueuePolicy
+	// ErrorHandler is called with errors sending queued messages, from the
+	// worker goroutines. The default is log.DefaultErrorHandler.
+	ErrorHandler func(error)
+	// CloseTimeout bounds how long Flush and Close wait for queued messages
+	// to be sent. The default is DefaultCloseTimeout.
+	CloseTimeout     time.Duration
 	ServerAddr       net.Addr
 	ClientPacketConn net.PacketConn
 }
 
+// Chunk sizes recommended by graylog.
+const (
+	// ChunkSizeWAN fits a packet within the 1500 byte MTU of most networks.
+	ChunkSizeWAN = 1420
+	// ChunkSizeLAN fits a packet within a 9000 byte jumbo frame.
+	ChunkSizeLAN = 8154
+)
+
+// Limits of the ChunkSize.
+const (
+	// MinChunkSize leaves room for more than the chunk header in a packet.
+	MinChunkSize = 64
+	// MaxChunkSize fits a packet within the largest UDP payload over IPv4.
+	MaxChunkSize = 65507 - chunkHeaderSize
+)
+
+// Compression is a payload encoding accepted by graylog GELF UDP inputs.
+type Compression int
+
+const (
+	// CompressGzip compresses messages with gzip.
+	CompressGzip Compression = iota
+	// CompressZlib compresses messages with zlib.
+	CompressZlib
+	// CompressNone sends messages as they are. Messages that fit in a single
+	// packet are sent without chunking.
+	CompressNone
+)
+
+// compressor is implemented by gzip.Writer and zlib.Writer.
+type compressor interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+// plainWriter is a compressor that does not compress.
+type plainWriter struct {
+	w io.Writer
+}
+
+func (pw *plainWriter) Write(p []byte) (int, error) { return pw.w.Write(p) }
+func (pw *plainWriter) Close() error                { return nil }
+func (pw *plainWriter) Reset(w io.Writer)           { pw.w = w }
+
+func newCompressor(c Compression, level int, w io.Writer) compressor {
+	switch c {
+	case CompressZlib:
+		zw, _ := zlib.NewWriterLevel(w, level)
+		return zw
+	case CompressNone:
+		return &plainWriter{w: w}
+	}
+	zw, _ := gzip.NewWriterLevel(w, level)
+	return zw
+}
+
 // New creates a Client with the Config provided
 func New(c Config) (*Client, error) {
 	if c.ClientPacketConn == nil {
 		return nil, fmt.Errorf("cannot create new Client without a connection")
 	}
 
+	if c.ChunkSize == 0 {
+		c.ChunkSize = ChunkSizeWAN
+	}
+	if c.ChunkSize < MinChunkSize || c.ChunkSize > MaxChunkSize {
+		return nil, fmt.Errorf(
+			"chunk size of %d is not between %d and %d",
+			c.ChunkSize, MinChunkSize, MaxChunkSize,
+		)
+	}
+
+	if c.Compression < CompressGzip || c.Compression > CompressNone {
+		return nil, fmt.Errorf("compression type %d is not a valid compression type", c.Compression)
+	}
+
+	if c.Oversize < OversizeError || c.Oversize > OversizeFallback {
+		return nil, fmt.Errorf("oversize policy %d is not a valid oversize policy", c.Oversize)
+	}
+	if c.Oversize == OversizeFallback && c.Fallback == nil {
+		return nil, fmt.Errorf("cannot use OversizeFallback without a Fallback")
+	}
+
+	if c.QueueSize < 0 {
+		return nil, fmt.Errorf("queue size of %d is not a valid queue size", c.QueueSize)
+	}
+	if c.QueueFull < QueueBlock || c.QueueFull > QueueDrop {
+		return nil, fmt.Errorf("queue policy %d is not a valid queue policy", c.QueueFull)
+	}
+	if c.Workers < 0 {
+		return nil, fmt.Errorf("worker count of %d is not a valid worker count", c.Workers)
+	}
+	if c.Workers == 0 {
+		c.Workers = 1
+	}
+	if c.ErrorHandler == nil {
+		c.ErrorHandler = log.DefaultErrorHandler
+	}
+	if c.CloseTimeout <= 0 {
+		c.CloseTimeout = DefaultCloseTimeout
+	}
+
 	if c.CompressionLevel != gzip.NoCompression &&
 		c.CompressionLevel != gzip.DefaultCompression &&
 		(c.CompressionLevel > gzip.BestCompression || c.CompressionLevel < gzip.BestSpeed) {
@@ -50,24 +189,35 @@ func New(c Config) (*Client, error) {
 	gl := &Client{
 		msgPool: sync.Pool{
 			New: func() interface{} {
-				msg := new(message)
-				msg.zip, _ = gzip.NewWriterLevel(&msg.buf, c.CompressionLevel)
+				msg := &message{
+					chunkSize:   c.ChunkSize,
+					chunkAlways: c.Compression != CompressNone,
+				}
+				msg.zip = newCompressor(c.Compression, c.CompressionLevel, &msg.buf)
 				return msg
 			},
 		},
-		addr: c.ServerAddr,
-		conn: c.ClientPacketConn,
+		addr:       c.ServerAddr,
+		conn:       c.ClientPacketConn,
+		chunkSize:  c.ChunkSize,
+		oversize:   c.Oversize,
+		fallback:   c.Fallback,
+		onOversize: c.OnOversize,
 	}
 
 	if _, err := rand.Read(gl.instanceID[0:4]); err != nil {
 		return nil, fmt.Errorf("creating unique ID for logging client: %+v", err)
 	}
+	if c.QueueSize > 0 {
+		gl.startWorkers(c)
+	}
 
 	return gl, nil
 }
 
 // Write sends the contents of a byte slice over a Packet Connection with
-// the graylog protocol.
+// the graylog protocol. When QueueSize is set, p is queued to be sent in the
+// background instead, and errors sending it are passed to ErrorHandler.
 func (gl *Client) Write(p []byte) (int, error) {
 	if len(p) == 0 {
 		return 0, nil
@@ -75,11 +225,35 @@ func (gl *Client) Write(p []byte) (int, error) {
 	if !bytes.HasSuffix(p, []byte("\n")) {
 		return 0, ErrMissingNewline
 	}
+	if gl.queue != nil {
+		return gl.queue.enqueue(p)
+	}
+	if atomic.LoadInt32(&gl.closed) == 1 {
+		return 0, ErrClosed
+	}
+	return gl.write(p)
+}
+
+func (gl *Client) write(p []byte) (int, error) {
+	n, err := gl.send(p)
+	if oe, ok := err.(*oversizeError); ok {
+		return gl.writeOversize(p, oe)
+	}
+	return n, err
+}
+
+func (gl *Client) send(p []byte) (int, error) {
 	msg := gl.newMessage()
 	defer gl.freeMessage(msg)
 	return msg.Write(p)
 }
 
+// MaxMessageSize is the largest message, after compression, that can be sent
+// with the configured ChunkSize.
+func (gl *Client) MaxMessageSize() int {
+	return maxChunkCount * gl.chunkSize
+}
+
 var ErrMissingNewline = errors.New("missing newline terminating write")
 
 func (gl *Client) newMessage() *message {
@@ -111,10 +285,15 @@ func (gl *Client) messageID() (id [8]byte) {
 
 type message struct {
 	buf  bytes.Buffer
-	zip  *gzip.Writer
+	zip  compressor
 	id   [8]byte
 	conn net.PacketConn
 	addr net.Addr
+	// chunkSize is the largest payload of a single packet.
+	chunkSize int
+	// chunkAlways sends single chunk messages with chunk headers, as was
+	// always done for gzip compressed messages.
+	chunkAlways bool
 }
 
 func (msg *message) Write(p []byte) (int, error) {
@@ -125,17 +304,24 @@ func (msg *message) Write(p []byte) (int, error) {
 	}
 
 	length := msg.buf.Len()
-	count, rem := length/maxChunkSize, length%maxChunkSize
+	count, rem := length/msg.chunkSize, length%msg.chunkSize
 	if rem > 0 {
 		count++
 	}
 
 	if count > maxChunkCount {
-		return 0, fmt.Errorf("message exceeds maximum size, %d > %d", length, maxChunkCount*maxChunkSize)
+		return 0, &oversizeError{size: length, max: maxChunkCount * msg.chunkSize}
+	}
+
+	if count == 1 && !msg.chunkAlways {
+		if _, err := msg.conn.WriteTo(msg.buf.Bytes(), msg.addr); err != nil {
+			return 0, fmt.Errorf("writing to udp connection: %+v", err)
+		}
+		return n, nil
 	}
 
-	packet := make([]byte, 0, mtuSize)
-	chunk := make([]byte, maxChunkSize)
+	packet := make([]byte, 0, chunkHeaderSize+msg.chunkSize)
+	chunk := make([]byte, msg.chunkSize)
 	for i := 0; i < count; i++ {
 		packet = append(packet, gelfMagicByteA, gelfMagicByteB) // magic GELF bytes
 		packet = append(packet, msg.id[0:8]...)
@@ -152,16 +338,15 @@ func (msg *message) Write(p []byte) (int, error) {
 		}
 
 		packet = packet[:0]
-		chunk = chunk[:maxChunkSize]
+		chunk = chunk[:msg.chunkSize]
 	}
 
 	return n, nil
 }
 
 const (
-	mtuSize        = 1500
-	maxChunkSize   = 1420 // based on MTU of 1500 and chunked GELF over UDP
-	maxChunkCount  = 128  // based on 1-byte int sequence max
-	gelfMagicByteA = 0x1e
-	gelfMagicByteB = 0x0f
+	chunkHeaderSize = 12  // magic bytes, message ID, and sequence
+	maxChunkCount   = 128 // based on 1-byte int sequence max
+	gelfMagicByteA  = 0x1e
+	gelfMagicByteB  = 0x0f
 )
@@ -3,8 +3,10 @@ package graylog_test
 import (
 	"bytes"
 	"compress/gzip"
+	"compress/zlib"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"reflect"
 	"strings"
@@ -278,6 +280,183 @@ func TestWriteZips(t *testing.T) {
 	}
 }
 
+func TestWriteCompression(t *testing.T) {
+	small := []byte("{\"short\":{\"pi\":3.14,\"phi\":1.618}}\n")
+	large := []byte(fmt.Sprintf("{\"long string\":\"%s\"}\n", strings.Repeat("pop ", 1000)))
+
+	testCases := []struct {
+		name        string
+		compression graylog.Compression
+		input       []byte
+		wantChunked bool
+		decompress  func(io.Reader) (io.Reader, error)
+	}{
+		{"zlib small message",
+			graylog.CompressZlib,
+			small,
+			true,
+			func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+		},
+		{"zlib large message",
+			graylog.CompressZlib,
+			large,
+			true,
+			func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+		},
+		{"uncompressed small message",
+			graylog.CompressNone,
+			small,
+			false,
+			func(r io.Reader) (io.Reader, error) { return r, nil },
+		},
+		{"uncompressed large message",
+			graylog.CompressNone,
+			large,
+			true,
+			func(r io.Reader) (io.Reader, error) { return r, nil },
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockAddr := mock_net.NewMockAddr(mockCtrl)
+			mockPacketConn := mock_net.NewMockPacketConn(mockCtrl)
+
+			w, err := graylog.New(graylog.Config{
+				Compression:      tc.compression,
+				ClientPacketConn: mockPacketConn,
+				ServerAddr:       mockAddr,
+			})
+			if err != nil {
+				t.Fatalf("error constructing New Client: %+v", err)
+			}
+
+			var buf bytes.Buffer
+			mockPacketConn.EXPECT().WriteTo(gomock.Any(), gomock.Eq(mockAddr)).Do(func(p []byte, addr net.Addr) (int, error) {
+				chunked := len(p) > 12 && p[0] == 0x1e && p[1] == 0x0f
+				if chunked != tc.wantChunked {
+					t.Errorf("for %s: got chunked packet? %v, expected %v", tc.name, chunked, tc.wantChunked)
+				}
+				if chunked {
+					p = p[12:]
+				}
+				buf.Write(p)
+				return len(p), nil
+			}).MinTimes(1)
+
+			if _, err := w.Write(tc.input); err != nil {
+				t.Fatalf("for %s: Write() returned unexpected error: %+v", tc.name, err)
+			}
+
+			r, err := tc.decompress(&buf)
+			if err != nil {
+				t.Fatalf("for %s: failed to create reader for results: %+v", tc.name, err)
+			}
+			output, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("for %s: reading results, got unexpected error: %+v", tc.name, err)
+			}
+			if !bytes.Equal(output, tc.input[:len(tc.input)-1]) {
+				t.Errorf("for %s: got %d bytes, expected different %d bytes", tc.name, len(output), len(tc.input)-1)
+			}
+		})
+	}
+}
+
+func TestNew_Compression(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockPacketConn := mock_net.NewMockPacketConn(mockCtrl)
+
+	for _, c := range []graylog.Compression{-1, graylog.CompressNone + 1} {
+		if _, err := graylog.New(graylog.Config{ClientPacketConn: mockPacketConn, Compression: c}); err == nil {
+			t.Errorf("New(Config{Compression:%d}) did not return an error", c)
+		}
+	}
+}
+
+func TestWriteChunkSize(t *testing.T) {
+	input := []byte(fmt.Sprintf("{\"long string\":\"%s\"}\n", strings.Repeat("pop ", 5000)))
+
+	testCases := []struct {
+		name       string
+		chunkSize  int
+		wantChunks int
+		wantMax    int
+	}{
+		{"default", 0, 15, 128 * graylog.ChunkSizeWAN},
+		{"WAN", graylog.ChunkSizeWAN, 15, 128 * graylog.ChunkSizeWAN},
+		{"LAN", graylog.ChunkSizeLAN, 3, 128 * graylog.ChunkSizeLAN},
+		{"small", 1000, 21, 128 * 1000},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockAddr := mock_net.NewMockAddr(mockCtrl)
+			mockPacketConn := mock_net.NewMockPacketConn(mockCtrl)
+
+			c, err := graylog.New(graylog.Config{
+				Compression:      graylog.CompressNone,
+				ChunkSize:        tc.chunkSize,
+				ClientPacketConn: mockPacketConn,
+				ServerAddr:       mockAddr,
+			})
+			if err != nil {
+				t.Fatalf("error constructing New Client: %+v", err)
+			}
+			if got := c.MaxMessageSize(); got != tc.wantMax {
+				t.Errorf("MaxMessageSize() = %d, expected %d", got, tc.wantMax)
+			}
+
+			size := tc.chunkSize
+			if size == 0 {
+				size = graylog.ChunkSizeWAN
+			}
+			var buf bytes.Buffer
+			mockPacketConn.EXPECT().WriteTo(gomock.Any(), gomock.Eq(mockAddr)).Do(func(p []byte, addr net.Addr) (int, error) {
+				if len(p) > size+12 {
+					t.Errorf("got packet of %d bytes, expected at most %d", len(p), size+12)
+				}
+				if int(p[11]) != tc.wantChunks {
+					t.Errorf("got chunk count of %d, expected %d", p[11], tc.wantChunks)
+				}
+				buf.Write(p[12:])
+				return len(p), nil
+			}).Times(tc.wantChunks)
+
+			if _, err := c.Write(input); err != nil {
+				t.Fatalf("Write() returned unexpected error: %+v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), input[:len(input)-1]) {
+				t.Errorf("got %d bytes, expected different %d bytes", buf.Len(), len(input)-1)
+			}
+		})
+	}
+}
+
+func TestNew_ChunkSize(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockPacketConn := mock_net.NewMockPacketConn(mockCtrl)
+
+	for _, size := range []int{-1, graylog.MinChunkSize - 1, graylog.MaxChunkSize + 1} {
+		if _, err := graylog.New(graylog.Config{ClientPacketConn: mockPacketConn, ChunkSize: size}); err == nil {
+			t.Errorf("New(Config{ChunkSize:%d}) did not return an error", size)
+		}
+	}
+	for _, size := range []int{graylog.MinChunkSize, graylog.MaxChunkSize} {
+		if _, err := graylog.New(graylog.Config{ClientPacketConn: mockPacketConn, ChunkSize: size}); err != nil {
+			t.Errorf("New(Config{ChunkSize:%d}) returned unexpected error: %+v", size, err)
+		}
+	}
+}
+
 func TestNew_Conn(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -0,0 +1,95 @@
+// Package binenc holds what the msgpack and cbor Encoders share: the framing
+// of encoded values on a stream, and the encoding/json fallback for values
+// without a binary representation of their own.
+package binenc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// MaxDepth bounds how deeply an Encoder recurses into nested values before
+// handing them to DecodeJSON, where encoding/json reports cycles as errors
+// rather than overflowing the stack.
+const MaxDepth = 100
+
+// Writer writes encoded values to an output stream, each in a single Write.
+// It is safe for concurrent use.
+type Writer struct {
+	w            io.Writer
+	lengthPrefix bool
+
+	mux sync.Mutex
+	buf []byte
+}
+
+// NewWriter returns a new Writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// SetLengthPrefix sets whether each value is preceded by its length as a
+// 4-byte big-endian integer, so that values can be read from a stream
+// transport without decoding them.
+func (fw *Writer) SetLengthPrefix(prefix bool) {
+	fw.lengthPrefix = prefix
+}
+
+// WriteValue writes the encoding of v made by appendValue. Nothing is written
+// if appendValue returns an error.
+func (fw *Writer) WriteValue(v interface{}, appendValue func([]byte, interface{}) ([]byte, error)) error {
+	fw.mux.Lock()
+	defer fw.mux.Unlock()
+
+	b := fw.buf[:0]
+	if fw.lengthPrefix {
+		b = append(b, 0, 0, 0, 0)
+	}
+	b, err := appendValue(b, v)
+	if err != nil {
+		return err
+	}
+	if fw.lengthPrefix {
+		binary.BigEndian.PutUint32(b[:4], uint32(len(b)-4))
+	}
+	fw.buf = b
+
+	_, err = fw.w.Write(b)
+	return err
+}
+
+// DecodeJSON returns v as decoded from its JSON encoding, with numbers as
+// json.Number, for types an Encoder does not represent itself.
+func DecodeJSON(v interface{}) (interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("decoding JSON fallback: %+v", err)
+	}
+	return decoded, nil
+}
+
+// AppendUint16 appends u in big-endian order.
+func AppendUint16(b []byte, u uint16) []byte {
+	return append(b, byte(u>>8), byte(u))
+}
+
+// AppendUint32 appends u in big-endian order.
+func AppendUint32(b []byte, u uint32) []byte {
+	return append(b, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+// AppendUint64 appends u in big-endian order.
+func AppendUint64(b []byte, u uint64) []byte {
+	return append(b, byte(u>>56), byte(u>>48), byte(u>>40), byte(u>>32),
+		byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
@@ -0,0 +1,56 @@
+package binenc_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/PermissionData/log/internal/binenc"
+)
+
+func appendString(b []byte, v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return b, errors.New("not a string")
+	}
+	return append(b, s...), nil
+}
+
+func TestWriteValue(t *testing.T) {
+	var buf bytes.Buffer
+	w := binenc.NewWriter(&buf)
+	if err := w.WriteValue("ab", appendString); err != nil {
+		t.Fatalf("WriteValue returned unexpected error: %+v", err)
+	}
+	w.SetLengthPrefix(true)
+	if err := w.WriteValue("cde", appendString); err != nil {
+		t.Fatalf("WriteValue returned unexpected error: %+v", err)
+	}
+	if err := w.WriteValue(1, appendString); err == nil {
+		t.Fatalf("WriteValue did not return the error from appendValue")
+	}
+	if got, want := hex.EncodeToString(buf.Bytes()), "6162"+"00000003636465"; got != want {
+		t.Errorf("WriteValue wrote %s, expected %s", got, want)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	got, err := binenc.DecodeJSON(struct {
+		A int
+		B []string
+	}{1, []string{"x"}})
+	if err != nil {
+		t.Fatalf("DecodeJSON returned unexpected error: %+v", err)
+	}
+	want := map[string]interface{}{"A": json.Number("1"), "B": []interface{}{"x"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeJSON returned %#v, expected %#v", got, want)
+	}
+
+	if _, err := binenc.DecodeJSON(make(chan int)); err == nil {
+		t.Errorf("DecodeJSON(<chan>) did not return an error")
+	}
+}
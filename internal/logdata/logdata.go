@@ -0,0 +1,21 @@
+// Package logdata holds what the Encoders that accept only log Data share.
+package logdata
+
+import (
+	"fmt"
+
+	"github.com/PermissionData/log"
+)
+
+// Assert returns v as log.Data when it is log.Data or a
+// map[string]interface{}. The error for any other value is prefixed with the
+// name of the Encoder.
+func Assert(encoder string, v interface{}) (log.Data, error) {
+	switch val := v.(type) {
+	case log.Data:
+		return val, nil
+	case map[string]interface{}:
+		return val, nil
+	}
+	return nil, fmt.Errorf("%s: cannot encode %T, expected log.Data", encoder, v)
+}
@@ -0,0 +1,35 @@
+package logdata_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/internal/logdata"
+)
+
+func TestAssert(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   interface{}
+		want    log.Data
+		wantErr string
+	}{
+		{"data", log.Data{"a": 1}, log.Data{"a": 1}, ""},
+		{"map", map[string]interface{}{"a": 1}, log.Data{"a": 1}, ""},
+		{"other", "a", nil, "test: cannot encode string, expected log.Data"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := logdata.Assert("test", tc.input)
+			if (err != nil || tc.wantErr != "") && (err == nil || err.Error() != tc.wantErr) {
+				t.Fatalf("Assert returned error %v, expected %q", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Assert returned %v, expected %v", got, tc.want)
+			}
+		})
+	}
+}
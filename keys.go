@@ -0,0 +1,37 @@
+package log
+
+import "sort"
+
+// Keys used for the fields added by the Filters in this package.
+const (
+	TimestampKey = "@timestamp"
+	VersionKey   = "@version"
+	LevelKey     = "log_level"
+	MessageKey   = "message"
+	StackKey     = "_stack"
+)
+
+// DefaultKeyOrder lists the keys that Encoders with a stable key order write
+// before all others.
+var DefaultKeyOrder = []string{TimestampKey, LevelKey, MessageKey}
+
+// SortKeys returns the keys of data with any keys listed in first at the
+// front, in the order given, followed by the remaining keys sorted.
+func SortKeys(data Data, first ...string) []string {
+	keys := make([]string, 0, len(data))
+	leading := make(map[string]bool, len(first))
+	for _, k := range first {
+		if _, ok := data[k]; ok && !leading[k] {
+			leading[k] = true
+			keys = append(keys, k)
+		}
+	}
+	n := len(keys)
+	for k := range data {
+		if !leading[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys[n:])
+	return keys
+}
@@ -0,0 +1,48 @@
+package log
+
+import "encoding/json"
+
+// Lazy is a value in Data that is only computed once an entry is going to be
+// encoded. Wrapping expensive values with Lazy ensures they are never built
+// for entries that a Filter discards.
+type Lazy func() interface{}
+
+// MarshalJSON computes the value and marshals the result, so that Lazy values
+// nested below the first level of Data are still resolved by json.Encoder.
+func (fn Lazy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fn.resolve())
+}
+
+func (fn Lazy) resolve() interface{} {
+	var v interface{} = fn
+	for {
+		lazy, ok := v.(Lazy)
+		if !ok {
+			return v
+		}
+		if lazy == nil {
+			return nil
+		}
+		v = lazy()
+	}
+}
+
+// ResolveFilter provides a Filter that replaces Lazy values in the Data with
+// their results. Loggers created with New resolve any remaining Lazy values
+// before encoding, so this is only needed ahead of Filters that inspect
+// lazily computed values. It should come after any Filter that may discard
+// the entry.
+func ResolveFilter() Filter {
+	return func(lvl, threshold Level, data Data) Data {
+		resolveLazy(data)
+		return data
+	}
+}
+
+func resolveLazy(data Data) {
+	for k, v := range data {
+		if lazy, ok := v.(Lazy); ok {
+			data[k] = lazy.resolve()
+		}
+	}
+}
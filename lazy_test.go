@@ -0,0 +1,117 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/PermissionData/log"
+	mock_log "github.com/PermissionData/log/mock"
+)
+
+func TestLazyOnlyResolvedWhenLogged(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockEncoder := mock_log.NewMockEncoder(mockCtrl)
+
+	lg := log.New(log.Config{
+		Threshold: log.ErrorLevel,
+		Encoder:   mockEncoder,
+		Filters: []log.Filter{
+			log.BaseFilter(),
+			func(lvl, threshold log.Level, data log.Data) log.Data {
+				delete(data, "@timestamp")
+				delete(data, "@version")
+				delete(data, "log_level")
+				return data
+			},
+		},
+	})
+
+	calls := 0
+	expensive := log.Lazy(func() interface{} {
+		calls++
+		return 3.14
+	})
+
+	lg.Log(log.InfoLevel, log.Data{"pi": expensive})
+	if calls != 0 {
+		t.Fatalf("Lazy value computed %d times for a discarded entry, expected 0", calls)
+	}
+
+	mockEncoder.EXPECT().Encode(gomock.Eq(log.Data{"pi": 3.14})).Times(1)
+	lg.Log(log.ErrorLevel, log.Data{"pi": expensive})
+	if calls != 1 {
+		t.Fatalf("Lazy value computed %d times for a logged entry, expected 1", calls)
+	}
+}
+
+func TestResolveFilter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		inData   log.Data
+		wantData log.Data
+	}{
+		{"nil data",
+			nil,
+			nil,
+		},
+		{"no lazy values",
+			log.Data{"pi": 3.14},
+			log.Data{"pi": 3.14},
+		},
+		{"lazy values",
+			log.Data{
+				"pi":  log.Lazy(func() interface{} { return 3.14 }),
+				"phi": 1.618,
+			},
+			log.Data{
+				"pi":  3.14,
+				"phi": 1.618,
+			},
+		},
+		{"lazy returning lazy",
+			log.Data{"pi": log.Lazy(func() interface{} {
+				return log.Lazy(func() interface{} { return 3.14 })
+			})},
+			log.Data{"pi": 3.14},
+		},
+		{"nil lazy",
+			log.Data{"pi": log.Lazy(nil)},
+			log.Data{"pi": nil},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			gotData := log.ResolveFilter()(log.InfoLevel, log.InfoLevel, tc.inData)
+			if (gotData == nil) != (tc.wantData == nil) || len(gotData) != len(tc.wantData) {
+				t.Fatalf("ResolveFilter()(InfoLevel, InfoLevel, %+v) = %+v, expected %+v", tc.inData, gotData, tc.wantData)
+			}
+			for k, v := range tc.wantData {
+				if gotData[k] != v {
+					t.Fatalf("ResolveFilter()(InfoLevel, InfoLevel, %+v)[%q] = %+v, expected %+v", tc.inData, k, gotData[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestLazyMarshalJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(log.Data{
+		"nested": map[string]interface{}{
+			"pi": log.Lazy(func() interface{} { return 3.14 }),
+		},
+		"nil": log.Lazy(nil),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error encoding lazy values: %+v", err)
+	}
+	if got, want := buf.String(), "{\"nested\":{\"pi\":3.14},\"nil\":null}\n"; got != want {
+		t.Fatalf("encoded lazy values as %q, expected %q", got, want)
+	}
+}
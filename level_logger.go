@@ -9,7 +9,11 @@ type LevelLogger interface {
 	Trace(Data)
 }
 
-var _ LevelLogger = &logWithLevels{}
+var (
+	_ LevelLogger = &logWithLevels{}
+	_ Enabler     = &logWithLevels{}
+	_ FieldLogger = &logWithLevels{}
+)
 
 type logWithLevels struct {
 	Logger
@@ -24,3 +28,10 @@ func (wl *logWithLevels) Fatal(data Data) { wl.Log(FatalLevel, data) }
 func (wl *logWithLevels) Error(data Data) { wl.Log(ErrorLevel, data) }
 func (wl *logWithLevels) Info(data Data)  { wl.Log(InfoLevel, data) }
 func (wl *logWithLevels) Trace(data Data) { wl.Log(TraceLevel, data) }
+
+// Enabled reports whether the wrapped Logger is enabled for lvl.
+func (wl *logWithLevels) Enabled(lvl Level) bool { return Enabled(wl.Logger, lvl) }
+
+// LogFields logs fields to the wrapped Logger, converting them to Data when it
+// does not implement FieldLogger.
+func (wl *logWithLevels) LogFields(lvl Level, fields ...Field) { LogFields(wl.Logger, lvl, fields...) }
@@ -0,0 +1,178 @@
+// Package logfmt provides an Encoder that writes log Data as logfmt, a line
+// of key=value pairs that is easy to read while tailing logs.
+package logfmt
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/internal/logdata"
+)
+
+var _ log.Encoder = &Encoder{}
+
+// Encoder writes log Data to an output stream as logfmt, one line per entry.
+// Keys listed in the key order come first, followed by the remaining keys
+// sorted.
+type Encoder struct {
+	w        io.Writer
+	keyOrder []string
+
+	mux sync.Mutex
+	buf []byte
+}
+
+// NewEncoder returns a new Encoder that writes to w using log.DefaultKeyOrder.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, keyOrder: log.DefaultKeyOrder}
+}
+
+// SetKeyOrder sets the keys that are written first, in the order given.
+func (enc *Encoder) SetKeyOrder(keys ...string) {
+	enc.keyOrder = keys
+}
+
+// Encode writes the logfmt encoding of v, which must be log.Data or a
+// map[string]interface{}, followed by a newline.
+func (enc *Encoder) Encode(v interface{}) error {
+	data, err := logdata.Assert("logfmt", v)
+	if err != nil {
+		return err
+	}
+
+	enc.mux.Lock()
+	defer enc.mux.Unlock()
+	b := enc.buf[:0]
+	for i, k := range log.SortKeys(data, enc.keyOrder...) {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		b = AppendKey(b, k)
+		b = append(b, '=')
+		b = AppendValue(b, data[k])
+	}
+	b = append(b, '\n')
+	enc.buf = b
+
+	_, err = enc.w.Write(b)
+	return err
+}
+
+// AppendKey appends k to b as a logfmt key, replacing any characters that are
+// not allowed in a key with underscores.
+func AppendKey(b []byte, k string) []byte {
+	if k == "" {
+		return append(b, '_')
+	}
+	for _, r := range k {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f || r == utf8.RuneError {
+			r = '_'
+		}
+		b = append(b, string(r)...)
+	}
+	return b
+}
+
+// AppendValue appends v to b as a logfmt value, quoting it when needed.
+// Values that are not scalars are written as their JSON encoding.
+func AppendValue(b []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case nil:
+		return append(b, "null"...)
+	case string:
+		return appendString(b, val)
+	case []byte:
+		return appendString(b, string(val))
+	case bool:
+		return strconv.AppendBool(b, val)
+	case int:
+		return strconv.AppendInt(b, int64(val), 10)
+	case int8:
+		return strconv.AppendInt(b, int64(val), 10)
+	case int16:
+		return strconv.AppendInt(b, int64(val), 10)
+	case int32:
+		return strconv.AppendInt(b, int64(val), 10)
+	case int64:
+		return strconv.AppendInt(b, val, 10)
+	case uint:
+		return strconv.AppendUint(b, uint64(val), 10)
+	case uint8:
+		return strconv.AppendUint(b, uint64(val), 10)
+	case uint16:
+		return strconv.AppendUint(b, uint64(val), 10)
+	case uint32:
+		return strconv.AppendUint(b, uint64(val), 10)
+	case uint64:
+		return strconv.AppendUint(b, val, 10)
+	case float32:
+		return appendFloat(b, float64(val), 32)
+	case float64:
+		return appendFloat(b, val, 64)
+	case time.Time:
+		return val.AppendFormat(b, time.RFC3339Nano)
+	case time.Duration:
+		return append(b, val.String()...)
+	case error:
+		return appendString(b, val.Error())
+	case json.Marshaler:
+		return appendJSON(b, v)
+	case encoding.TextMarshaler:
+		txt, err := val.MarshalText()
+		if err != nil {
+			return appendString(b, err.Error())
+		}
+		return appendString(b, string(txt))
+	case fmt.Stringer:
+		return appendString(b, val.String())
+	}
+	return appendJSON(b, v)
+}
+
+// appendJSON appends the JSON encoding of v, or the error text when v cannot
+// be encoded, such as when it contains itself.
+func appendJSON(b []byte, v interface{}) []byte {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return appendString(b, err.Error())
+	}
+	return appendString(b, string(raw))
+}
+
+func appendFloat(b []byte, f float64, bits int) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.AppendFloat(b, f, 'g', -1, bits)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	return strconv.AppendFloat(b, f, format, -1, bits)
+}
+
+func appendString(b []byte, s string) []byte {
+	if !needsQuotes(s) {
+		return append(b, s...)
+	}
+	return strconv.AppendQuote(b, s)
+}
+
+func needsQuotes(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f || r == utf8.RuneError {
+			return true
+		}
+	}
+	return false
+}
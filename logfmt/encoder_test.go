@@ -0,0 +1,128 @@
+package logfmt_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/logfmt"
+)
+
+func TestEncode(t *testing.T) {
+	when := time.Date(2017, 1, 2, 3, 4, 5, 600000000, time.UTC)
+
+	testCases := []struct {
+		name  string
+		input interface{}
+		want  string
+	}{
+		{"empty data",
+			log.Data{},
+			"\n",
+		},
+		{"well-known keys first",
+			log.Data{
+				"zeta":           1,
+				"alpha":          2,
+				log.MessageKey:   "hello",
+				log.LevelKey:     log.InfoLevel,
+				log.TimestampKey: "2017-01-02T03:04:05.000Z",
+			},
+			"@timestamp=2017-01-02T03:04:05.000Z log_level=Info message=hello alpha=2 zeta=1\n",
+		},
+		{"map",
+			map[string]interface{}{"b": true, "a": nil},
+			"a=null b=true\n",
+		},
+		{"quoting",
+			log.Data{
+				"empty":   "",
+				"space":   "hello world",
+				"equals":  "a=b",
+				"quote":   `say "hi"`,
+				"newline": "a\nb",
+				"slash":   `a\b`,
+				"unicode": "héllo",
+			},
+			`empty="" equals="a=b" newline="a\nb" quote="say \"hi\"" slash="a\\b" space="hello world" unicode=héllo` + "\n",
+		},
+		{"numbers",
+			log.Data{
+				"int":   -42,
+				"uint":  uint8(7),
+				"float": 3.14,
+				"large": 1e21,
+				"small": float32(0.5),
+				"nan":   math.NaN(),
+			},
+			"float=3.14 int=-42 large=1e+21 nan=NaN small=0.5 uint=7\n",
+		},
+		{"other values",
+			log.Data{
+				"when":  when,
+				"dur":   1500 * time.Millisecond,
+				"err":   fmt.Errorf("it broke"),
+				"bytes": []byte("raw"),
+				"obj":   map[string]interface{}{"pi": 3.14},
+				"list":  []int{1, 2},
+			},
+			`bytes=raw dur=1.5s err="it broke" list=[1,2] obj="{\"pi\":3.14}" when=2017-01-02T03:04:05.6Z` + "\n",
+		},
+		{"invalid keys",
+			log.Data{"a b": 1, "c=d": 2, "": 3},
+			"_=3 a_b=1 c_d=2\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := logfmt.NewEncoder(&buf).Encode(tc.input); err != nil {
+				t.Fatalf("Encode(%+v) returned unexpected error: %+v", tc.input, err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("Encode(%+v) wrote %q, expected %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodeCycle(t *testing.T) {
+	data := log.Data{"a": 1}
+	data["self"] = data
+
+	var buf bytes.Buffer
+	if err := logfmt.NewEncoder(&buf).Encode(data); err != nil {
+		t.Fatalf("Encode(<cycle>) returned unexpected error: %+v", err)
+	}
+	if got := buf.String(); !strings.HasPrefix(got, `a=1 self="json: unsupported value: `) {
+		t.Errorf("Encode(<cycle>) wrote %q, expected the error for self", got)
+	}
+}
+
+func TestEncodeRejectsOtherTypes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := logfmt.NewEncoder(&buf).Encode("just a string"); err == nil {
+		t.Fatalf("Encode(\"just a string\") did not return an error")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Encode(\"just a string\") wrote %q, expected nothing", buf.String())
+	}
+}
+
+func TestSetKeyOrder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := logfmt.NewEncoder(&buf)
+	enc.SetKeyOrder("b", "missing", "a")
+	if err := enc.Encode(log.Data{"a": 1, "b": 2, "c": 3}); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	if got, want := buf.String(), "b=2 a=1 c=3\n"; got != want {
+		t.Errorf("Encode with key order wrote %q, expected %q", got, want)
+	}
+}
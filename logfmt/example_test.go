@@ -0,0 +1,34 @@
+package logfmt_test
+
+import (
+	"os"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/logfmt"
+)
+
+func ExampleNewEncoder() {
+	logger := log.New(log.Config{
+		Threshold: log.TraceLevel,
+		Encoder:   logfmt.NewEncoder(os.Stdout),
+		Filters: []log.Filter{
+			log.DefaultFilter,
+			func(lvl, threshold log.Level, data log.Data) log.Data {
+				// just for the example output
+				if data == nil {
+					return nil
+				}
+				data["@timestamp"] = "2017-01-02T03:04:05.000Z"
+				return data
+			},
+		},
+	})
+
+	logger.Log(log.InfoLevel, log.Data{
+		"message": "user logged in",
+		"user":    "jdoe",
+		"pi":      3.14,
+	})
+	// Output:
+	// @timestamp=2017-01-02T03:04:05.000Z log_level=Info message="user logged in" @version=1 pi=3.14 user=jdoe
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"runtime/debug"
+	"sync"
+	"time"
 )
 
 // Logger defines the bare minimum interface for logging structured data
@@ -12,6 +15,22 @@ type Logger interface {
 	Log(Level, Data)
 }
 
+// Enabler is implemented by Loggers that can report whether an entry at a
+// Level would be logged, so that callers can skip building Data that would
+// only be discarded.
+type Enabler interface {
+	Enabled(Level) bool
+}
+
+// Enabled reports whether lg would log an entry at lvl. Loggers that do not
+// implement Enabler are assumed to log every Level.
+func Enabled(lg Logger, lvl Level) bool {
+	if en, ok := lg.(Enabler); ok {
+		return en.Enabled(lvl)
+	}
+	return true
+}
+
 // Data provides an easily marshaled payload for structured logging. While an
 // empty interface alone would satisfy the most basic requirements for
 // structured logging, string keys on the first level allow better performance
@@ -28,12 +47,31 @@ type Encoder interface {
 // DefaultEncoder ensures that a New logger does not requre an explicit Encoder
 var DefaultEncoder Encoder = json.NewEncoder(os.Stdout)
 
-var _ Logger = &logger{}
+// DefaultErrorHandler ensures that a New logger does not require an explicit
+// ErrorHandler. It writes errors to stderr.
+var DefaultErrorHandler = func(err error) {
+	// I'm ambivalent on printing anything to stdout/stderr, but this should probably happen. (jallen)
+	// I agree. (rrichardson)
+	fmt.Fprintf(os.Stderr, "Error writing to log: %+v\n", err)
+}
+
+var (
+	_ Logger      = &logger{}
+	_ Enabler     = &logger{}
+	_ FieldLogger = &logger{}
+)
 
 type logger struct {
-	encoder   Encoder
-	filters   []Filter
-	threshold Level
+	encoder      Encoder
+	filters      []Filter
+	fieldFilters []FieldFilter
+	dataFilters  []Filter
+	threshold    Level
+	errorHandler func(error)
+	fallback     bool
+	// filtered is set when the Filters are known to drop entries beyond the
+	// threshold.
+	filtered bool
 }
 
 // Config contains the values that will be used by a new Logger
@@ -41,34 +79,153 @@ type Config struct {
 	Threshold Level
 	Encoder   Encoder
 	Filters   []Filter
+	// FieldFilters are applied to entries logged with LogFields, ahead of
+	// any Filters. When neither are set, the DefaultFieldFilter is used. Any
+	// Filters require the Fields to be converted to Data, so leave Filters
+	// empty to log Fields without allocating a map.
+	FieldFilters []FieldFilter
+	// ErrorHandler is called with any error encountered while logging,
+	// including a *PanicError for any panic recovered from a Filter or the
+	// Encoder.
+	ErrorHandler func(error)
+	// Fallback causes a minimal entry describing a recovered panic to be
+	// encoded in place of the entry that could not be logged.
+	Fallback bool
+	// ThresholdFiltered declares that the Filters and FieldFilters drop
+	// entries beyond the Threshold, as BaseFilter does, so that Enabled can
+	// report those Levels as disabled. It is implied when neither are set.
+	ThresholdFiltered bool
 }
 
 // New provides a basic Logger using the provided configuration.
 func New(config Config) Logger {
 	lg := &logger{
-		encoder:   config.Encoder,
-		filters:   config.Filters,
-		threshold: config.Threshold,
+		encoder:      config.Encoder,
+		filters:      config.Filters,
+		fieldFilters: config.FieldFilters,
+		dataFilters:  config.Filters,
+		threshold:    config.Threshold,
+		errorHandler: config.ErrorHandler,
+		fallback:     config.Fallback,
+		filtered:     config.ThresholdFiltered,
 	}
 	if len(lg.filters) == 0 {
 		lg.filters = []Filter{DefaultFilter}
+		if len(lg.fieldFilters) == 0 {
+			lg.fieldFilters = []FieldFilter{DefaultFieldFilter}
+			lg.filtered = true
+		}
 	}
 	if config.Encoder == nil {
 		lg.encoder = DefaultEncoder
 	}
+	if config.ErrorHandler == nil {
+		lg.errorHandler = DefaultErrorHandler
+	}
 	return lg
 }
 
 func (lg *logger) Log(lvl Level, data Data) {
-	for _, fn := range lg.filters {
+	defer lg.recoverPanic(lvl)
+	lg.log(lvl, data, lg.filters)
+}
+
+func (lg *logger) log(lvl Level, data Data, filters []Filter) {
+	for _, fn := range filters {
 		if data = fn(lvl, lg.threshold, data); data == nil {
 			return
 		}
 	}
+	resolveLazy(data)
 
 	if err := lg.encoder.Encode(data); err != nil {
-		// I'm ambivalent on printing anything to stdout/stderr, but this should probably happen. (jallen)
-		// I agree. (rrichardson)
-		fmt.Fprintf(os.Stderr, "Error writing to log: %+v\n", err)
+		lg.errorHandler(err)
 	}
 }
+
+// fieldsPool holds buffers with room for Filters to add Fields without
+// allocating.
+var fieldsPool = sync.Pool{
+	New: func() interface{} {
+		fields := make([]Field, 0, 16)
+		return &fields
+	},
+}
+
+// LogFields logs fields through the FieldFilters, and then through any
+// Filters, converting the Fields to Data only when Filters are configured or
+// the Encoder does not implement FieldEncoder.
+func (lg *logger) LogFields(lvl Level, fields ...Field) {
+	defer lg.recoverPanic(lvl)
+
+	buf := fieldsPool.Get().(*[]Field)
+	defer func() {
+		// clear whatever the Filters may have appended in place
+		all := (*buf)[:cap(*buf)]
+		for i := range all {
+			all[i] = Field{}
+		}
+		fieldsPool.Put(buf)
+	}()
+	fields = append((*buf)[:0], fields...)
+	*buf = fields[:0]
+
+	for _, fn := range lg.fieldFilters {
+		if fields = fn(lvl, lg.threshold, fields); fields == nil {
+			return
+		}
+	}
+
+	if len(lg.dataFilters) > 0 {
+		lg.log(lvl, FieldsData(fields), lg.dataFilters)
+		return
+	}
+	var err error
+	if fe, ok := lg.encoder.(FieldEncoder); ok {
+		err = fe.EncodeFields(fields)
+	} else {
+		data := FieldsData(fields)
+		resolveLazy(data)
+		err = lg.encoder.Encode(data)
+	}
+	if err != nil {
+		lg.errorHandler(err)
+	}
+}
+
+// recoverPanic reports any panic while logging an entry, encoding the
+// fallback entry when configured.
+func (lg *logger) recoverPanic(lvl Level) {
+	if r := recover(); r != nil {
+		err := &PanicError{Value: r, Stack: debug.Stack()}
+		lg.errorHandler(err)
+		if lg.fallback {
+			lg.logFallback(lvl, err)
+		}
+	}
+}
+
+// logFallback encodes an entry describing err without any Filters.
+func (lg *logger) logFallback(lvl Level, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			lg.errorHandler(&PanicError{Value: r, Stack: debug.Stack()})
+		}
+	}()
+
+	if err := lg.encoder.Encode(Data{
+		TimestampKey: time.Now().UTC().Format(DefaultTimestampFormat),
+		VersionKey:   "1",
+		LevelKey:     lvl,
+		"log_error":  err.Error(),
+	}); err != nil {
+		lg.errorHandler(err)
+	}
+}
+
+// Enabled reports whether lvl is within the threshold of the logger, by the
+// same rule used by BaseFilter. Every Level is enabled when custom Filters
+// are not declared to apply the threshold, since they may log any entry.
+func (lg *logger) Enabled(lvl Level) bool {
+	return !lg.filtered || !exceedsThreshold(lvl, lg.threshold)
+}
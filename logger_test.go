@@ -1,6 +1,8 @@
 package log_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -142,6 +144,199 @@ func TestNewUsesThreshold(t *testing.T) {
 	}
 }
 
+func TestLogRecoversPanics(t *testing.T) {
+	panicFilter := func(lvl, threshold log.Level, data log.Data) log.Data {
+		panic("bad filter")
+	}
+	panicEncode := func(interface{}) error {
+		panic("bad encoder")
+	}
+
+	testCases := []struct {
+		name      string
+		filters   []log.Filter
+		encode    func(interface{}) error
+		fallback  bool
+		wantPanic string
+	}{
+		{"filter panics",
+			[]log.Filter{Pi, panicFilter},
+			nil,
+			false,
+			"bad filter",
+		},
+		{"encoder panics",
+			[]log.Filter{Pi},
+			panicEncode,
+			false,
+			"bad encoder",
+		},
+		{"filter panics with fallback",
+			[]log.Filter{Pi, panicFilter},
+			nil,
+			true,
+			"bad filter",
+		},
+		{"lazy value panics with fallback",
+			[]log.Filter{func(lvl, threshold log.Level, data log.Data) log.Data {
+				data["lazy"] = log.Lazy(func() interface{} { panic("bad value") })
+				return data
+			}},
+			nil,
+			true,
+			"bad value",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockEncoder := mock_log.NewMockEncoder(mockCtrl)
+			if tc.encode != nil {
+				mockEncoder.EXPECT().Encode(gomock.Any()).Times(1).Do(tc.encode)
+			}
+			if tc.fallback {
+				mockEncoder.EXPECT().Encode(gomock.Any()).Times(1).Do(func(v interface{}) {
+					data := v.(log.Data)
+					if data["log_level"] != log.ErrorLevel {
+						t.Errorf("fallback entry log_level = %v, expected %v", data["log_level"], log.ErrorLevel)
+					}
+					if msg, _ := data["log_error"].(string); msg != "panic while logging: "+tc.wantPanic {
+						t.Errorf("fallback entry log_error = %q, expected the panic to be described", msg)
+					}
+					if _, ok := data["pi"]; ok {
+						t.Errorf("fallback entry should not include filtered data, got %+v", data)
+					}
+				})
+			}
+
+			var reported []error
+			lg := log.New(log.Config{
+				Encoder:      mockEncoder,
+				Filters:      tc.filters,
+				ErrorHandler: func(err error) { reported = append(reported, err) },
+				Fallback:     tc.fallback,
+			})
+			lg.Log(log.ErrorLevel, log.Data{})
+
+			if len(reported) != 1 {
+				t.Fatalf("reported %d errors, expected 1: %v", len(reported), reported)
+			}
+			perr, ok := reported[0].(*log.PanicError)
+			if !ok {
+				t.Fatalf("reported %T, expected *log.PanicError", reported[0])
+			}
+			if perr.Value != tc.wantPanic {
+				t.Errorf("reported panic value %v, expected %v", perr.Value, tc.wantPanic)
+			}
+		})
+	}
+}
+
+func TestLogReportsEncoderErrors(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockEncoder := mock_log.NewMockEncoder(mockCtrl)
+	encodeErr := fmt.Errorf("encoding failed")
+	mockEncoder.EXPECT().Encode(gomock.Any()).Times(1).Return(encodeErr)
+
+	var reported []error
+	lg := log.New(log.Config{
+		Encoder:      mockEncoder,
+		Filters:      []log.Filter{Pi},
+		ErrorHandler: func(err error) { reported = append(reported, err) },
+		Fallback:     true,
+	})
+	lg.Log(log.ErrorLevel, log.Data{})
+
+	if len(reported) != 1 || reported[0] != encodeErr {
+		t.Fatalf("reported %v, expected only %v", reported, encodeErr)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	testCases := []struct {
+		name      string
+		lvl       log.Level
+		threshold log.Level
+		want      bool
+	}{
+		{"at threshold", log.ErrorLevel, log.ErrorLevel, true},
+		{"more severe", log.FatalLevel, log.ErrorLevel, true},
+		{"less severe", log.InfoLevel, log.ErrorLevel, false},
+		{"trace at trace", log.TraceLevel, log.TraceLevel, true},
+		{"custom level", log.TraceLevel + 1, log.FatalLevel, true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			lg := log.New(log.Config{Threshold: tc.threshold})
+			if got := log.Enabled(lg, tc.lvl); got != tc.want {
+				t.Errorf("Enabled(New(Config{Threshold: %v}), %v) = %v, expected %v", tc.threshold, tc.lvl, got, tc.want)
+			}
+			if got := log.Enabled(log.WithLevels(lg), tc.lvl); got != tc.want {
+				t.Errorf("Enabled(WithLevels(New(Config{Threshold: %v})), %v) = %v, expected %v", tc.threshold, tc.lvl, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEnabledWithFilters(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config log.Config
+		want   bool
+	}{
+		{
+			name:   "custom filters",
+			config: log.Config{Filters: []log.Filter{log.ErrorFilter("err")}},
+			want:   true,
+		},
+		{
+			name: "declared threshold filtered",
+			config: log.Config{
+				Filters:           []log.Filter{log.DefaultFilter, log.ErrorFilter("err")},
+				ThresholdFiltered: true,
+			},
+			want: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.config.Threshold = log.ErrorLevel
+			tc.config.Encoder = json.NewEncoder(&buf)
+			lg := log.New(tc.config)
+			if got := log.Enabled(lg, log.InfoLevel); got != tc.want {
+				t.Errorf("Enabled(lg, InfoLevel) = %v, expected %v", got, tc.want)
+			}
+			if lg.Log(log.InfoLevel, log.Data{}); (buf.Len() > 0) != tc.want {
+				t.Errorf("logged %q at InfoLevel, expected logged? %v", buf.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestEnabledWithoutEnabler(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockLogger := mock_log.NewMockLogger(mockCtrl)
+
+	for lvl := log.FatalLevel; lvl <= log.TraceLevel+1; lvl++ {
+		if !log.Enabled(mockLogger, lvl) {
+			t.Errorf("Enabled(<Logger>, %v) = false, expected Loggers without Enabled to be enabled", lvl)
+		}
+		if !log.Enabled(log.WithLevels(mockLogger), lvl) {
+			t.Errorf("Enabled(WithLevels(<Logger>), %v) = false, expected Loggers without Enabled to be enabled", lvl)
+		}
+	}
+}
+
 func Pi(lvl, threshold log.Level, data log.Data) log.Data {
 	if data == nil {
 		return nil
@@ -0,0 +1,42 @@
+package msgpack_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/msgpack"
+)
+
+func BenchmarkEncode(b *testing.B) {
+	data := log.Data{
+		"@timestamp": time.Now().UTC().Format(log.DefaultTimestampFormat),
+		"@version":   "1",
+		"log_level":  log.InfoLevel,
+		"message":    "request completed",
+		"status":     200,
+		"duration":   0.0123,
+		"path":       "/api/v1/users",
+		"ok":         true,
+		"error":      fmt.Errorf("none"),
+		"tags":       []interface{}{"a", "b", "c"},
+	}
+
+	b.Run("JSON", func(b *testing.B) {
+		enc := json.NewEncoder(ioutil.Discard)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			enc.Encode(data)
+		}
+	})
+	b.Run("MessagePack", func(b *testing.B) {
+		enc := msgpack.NewEncoder(ioutil.Discard)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			enc.Encode(data)
+		}
+	})
+}
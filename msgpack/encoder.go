@@ -0,0 +1,236 @@
+// Package msgpack provides an Encoder that writes log Data as MessagePack, a
+// compact binary alternative to JSON for high-volume pipelines.
+package msgpack
+
+import (
+	"encoding"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/internal/binenc"
+)
+
+var _ log.Encoder = &Encoder{}
+
+// Encoder writes values to an output stream as MessagePack. Levels and other
+// encoding.TextMarshalers are written as strings, errors as their messages,
+// and time.Time with the MessagePack timestamp extension. Other values are
+// written as their JSON encoding would be.
+type Encoder struct {
+	w *binenc.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: binenc.NewWriter(w)}
+}
+
+// SetLengthPrefix sets whether each value is preceded by its length as a
+// 4-byte big-endian integer.
+func (enc *Encoder) SetLengthPrefix(prefix bool) {
+	enc.w.SetLengthPrefix(prefix)
+}
+
+// Encode writes the MessagePack encoding of v.
+func (enc *Encoder) Encode(v interface{}) error {
+	return enc.w.WriteValue(v, func(b []byte, v interface{}) ([]byte, error) {
+		return appendValue(b, v, 0)
+	})
+}
+
+func appendValue(b []byte, v interface{}, depth int) ([]byte, error) {
+	if depth > binenc.MaxDepth {
+		return appendFallback(b, v)
+	}
+	switch val := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if val {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case string:
+		return appendString(b, val), nil
+	case []byte:
+		return appendBytes(b, val), nil
+	case int:
+		return appendInt(b, int64(val)), nil
+	case int8:
+		return appendInt(b, int64(val)), nil
+	case int16:
+		return appendInt(b, int64(val)), nil
+	case int32:
+		return appendInt(b, int64(val)), nil
+	case int64:
+		return appendInt(b, val), nil
+	case uint:
+		return appendUint(b, uint64(val)), nil
+	case uint8:
+		return appendUint(b, uint64(val)), nil
+	case uint16:
+		return appendUint(b, uint64(val)), nil
+	case uint32:
+		return appendUint(b, uint64(val)), nil
+	case uint64:
+		return appendUint(b, val), nil
+	case float32:
+		b = append(b, 0xca)
+		return binenc.AppendUint32(b, math.Float32bits(val)), nil
+	case float64:
+		b = append(b, 0xcb)
+		return binenc.AppendUint64(b, math.Float64bits(val)), nil
+	case log.Data:
+		return appendMap(b, val, depth)
+	case map[string]interface{}:
+		return appendMap(b, val, depth)
+	case []interface{}:
+		b = appendLength(b, len(val), 0x90, 0xdc, 0xdd)
+		var err error
+		for _, item := range val {
+			if b, err = appendValue(b, item, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case time.Time:
+		return appendTime(b, val), nil
+	case log.Lazy:
+		if val == nil {
+			return append(b, 0xc0), nil
+		}
+		return appendValue(b, val(), depth+1)
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return appendInt(b, i), nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return b, err
+		}
+		return appendValue(b, f, depth)
+	case error:
+		return appendString(b, val.Error()), nil
+	case json.Marshaler:
+		return appendFallback(b, v)
+	case encoding.TextMarshaler:
+		txt, err := val.MarshalText()
+		if err != nil {
+			return b, err
+		}
+		return appendString(b, string(txt)), nil
+	}
+	return appendFallback(b, v)
+}
+
+// appendFallback writes the value of v's JSON encoding, for types without a
+// MessagePack representation of their own.
+func appendFallback(b []byte, v interface{}) ([]byte, error) {
+	decoded, err := binenc.DecodeJSON(v)
+	if err != nil {
+		return b, err
+	}
+	return appendValue(b, decoded, 0)
+}
+
+func appendMap(b []byte, m map[string]interface{}, depth int) ([]byte, error) {
+	b = appendLength(b, len(m), 0x80, 0xde, 0xdf)
+	var err error
+	for k, v := range m {
+		b = appendString(b, k)
+		if b, err = appendValue(b, v, depth+1); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binenc.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binenc.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendBytes(b []byte, p []byte) []byte {
+	n := len(p)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binenc.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binenc.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, p...)
+}
+
+// appendLength writes the header of an array or map, using the fixed format
+// for small lengths.
+func appendLength(b []byte, n int, fix, code16, code32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binenc.AppendUint16(append(b, code16), uint16(n))
+	}
+	return binenc.AppendUint32(append(b, code32), uint32(n))
+}
+
+func appendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binenc.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binenc.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binenc.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+func appendUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 127:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binenc.AppendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binenc.AppendUint32(append(b, 0xce), uint32(u))
+	}
+	return binenc.AppendUint64(append(b, 0xcf), u)
+}
+
+// appendTime writes t with the timestamp extension type -1, using the
+// smallest of its three formats.
+func appendTime(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	switch {
+	case nsec == 0 && sec >= 0 && sec <= math.MaxUint32:
+		b = append(b, 0xd6, 0xff)
+		return binenc.AppendUint32(b, uint32(sec))
+	case sec >= 0 && sec>>34 == 0:
+		b = append(b, 0xd7, 0xff)
+		return binenc.AppendUint64(b, uint64(nsec)<<34|uint64(sec))
+	}
+	b = append(b, 0xc7, 12, 0xff)
+	b = binenc.AppendUint32(b, uint32(nsec))
+	return binenc.AppendUint64(b, uint64(sec))
+}
@@ -0,0 +1,115 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/msgpack"
+)
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input interface{}
+		want  string // hex
+	}{
+		{"nil", nil, "c0"},
+		{"true", true, "c3"},
+		{"false", false, "c2"},
+		{"positive fixint", 1, "01"},
+		{"negative fixint", -1, "ff"},
+		{"int8", -33, "d0df"},
+		{"int16", -300, "d1fed4"},
+		{"uint8", 200, "ccc8"},
+		{"uint32", uint32(70000), "ce00011170"},
+		{"int64", int64(1) << 40, "cf0000010000000000"},
+		{"float32", float32(1.5), "ca3fc00000"},
+		{"float64", 1.5, "cb3ff8000000000000"},
+		{"fixstr", "hi", "a26869"},
+		{"str8", strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{"bin8", []byte{1, 2}, "c4020102"},
+		{"array", []interface{}{1, "a"}, "9201a161"},
+		{"data", log.Data{"a": 1}, "81a16101"},
+		{"map", map[string]interface{}{"a": nil}, "81a161c0"},
+		{"level", log.InfoLevel, "a4496e666f"},
+		{"error", fmt.Errorf("no"), "a26e6f"},
+		{"timestamp 32", time.Unix(1, 0), "d6ff00000001"},
+		{"timestamp 64", time.Unix(1, 1), "d7ff0000000400000001"},
+		{"timestamp 96", time.Unix(-1, 0), "c70cff00000000ffffffffffffffff"},
+		{"lazy", log.Lazy(func() interface{} { return 1 }), "01"},
+		{"struct fallback", struct{ A int }{1}, "81a14101"},
+		{"typed slice fallback", []int{1, 2}, "920102"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := msgpack.NewEncoder(&buf).Encode(tc.input); err != nil {
+				t.Fatalf("Encode(%+v) returned unexpected error: %+v", tc.input, err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != tc.want {
+				t.Errorf("Encode(%+v) wrote %s, expected %s", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodeLengthPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetLengthPrefix(true)
+	if err := enc.Encode(log.Data{"a": 1}); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	if err := enc.Encode("hi"); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	if got, want := hex.EncodeToString(buf.Bytes()), "0000000481a16101"+"00000003a26869"; got != want {
+		t.Errorf("Encode with length prefix wrote %s, expected %s", got, want)
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := msgpack.NewEncoder(&buf).Encode(log.Data{"ch": make(chan int)}); err == nil {
+		t.Fatalf("Encode(<chan>) did not return an error")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Encode(<chan>) wrote %x despite an error", buf.Bytes())
+	}
+}
+
+func TestEncodeCycle(t *testing.T) {
+	data := log.Data{"a": 1}
+	data["self"] = data
+
+	var buf bytes.Buffer
+	if err := msgpack.NewEncoder(&buf).Encode(data); err == nil {
+		t.Fatalf("Encode(<cycle>) did not return an error")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Encode(<cycle>) wrote %x despite an error", buf.Bytes())
+	}
+}
+
+func TestEncodeDeep(t *testing.T) {
+	var deep interface{} = "bottom"
+	for i := 0; i < 200; i++ {
+		deep = []interface{}{deep}
+	}
+
+	var buf bytes.Buffer
+	if err := msgpack.NewEncoder(&buf).Encode(deep); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	// 200 one-item array heads, then the string head and "bottom"
+	if got := buf.Bytes(); len(got) != 207 || !bytes.HasSuffix(got, []byte("bottom")) {
+		t.Errorf("Encode wrote %x, expected 200 nested arrays around \"bottom\"", got)
+	}
+}
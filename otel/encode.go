@@ -0,0 +1,281 @@
+package otel
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// scope identifies the resource and instrumentation scope of exported
+// records.
+type scope struct {
+	resource []keyValue
+	name     string
+	version  string
+}
+
+// appendJSON appends an OTLP/JSON ExportLogsServiceRequest. As required by
+// OTLP/JSON, 64-bit integers are written as strings and IDs as hex.
+func appendJSON(b []byte, s scope, records []logRecord) []byte {
+	b = append(b, `{"resourceLogs":[{"resource":{"attributes":`...)
+	b = appendJSONKeyValues(b, s.resource)
+	b = append(b, `},"scopeLogs":[{"scope":{"name":`...)
+	b = appendJSONString(b, s.name)
+	if s.version != "" {
+		b = append(b, `,"version":`...)
+		b = appendJSONString(b, s.version)
+	}
+	b = append(b, `},"logRecords":[`...)
+	for i, r := range records {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, `{"timeUnixNano":"`...)
+		b = strconv.AppendInt(b, r.timeUnixNano, 10)
+		b = append(b, `","observedTimeUnixNano":"`...)
+		b = strconv.AppendInt(b, r.observedTimeUnixNano, 10)
+		b = append(b, `","severityNumber":`...)
+		b = strconv.AppendInt(b, int64(r.severityNumber), 10)
+		b = append(b, `,"severityText":`...)
+		b = appendJSONString(b, r.severityText)
+		if r.body.kind != emptyValue {
+			b = append(b, `,"body":`...)
+			b = appendJSONValue(b, r.body)
+		}
+		b = append(b, `,"attributes":`...)
+		b = appendJSONKeyValues(b, r.attributes)
+		if r.traceID != nil {
+			b = append(b, `,"traceId":"`...)
+			b = append(b, hex.EncodeToString(r.traceID)...)
+			b = append(b, '"')
+		}
+		if r.spanID != nil {
+			b = append(b, `,"spanId":"`...)
+			b = append(b, hex.EncodeToString(r.spanID)...)
+			b = append(b, '"')
+		}
+		b = append(b, '}')
+	}
+	return append(b, `]}]}]}`...)
+}
+
+func appendJSONKeyValues(b []byte, kvs []keyValue) []byte {
+	b = append(b, '[')
+	for i, kv := range kvs {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, `{"key":`...)
+		b = appendJSONString(b, kv.key)
+		b = append(b, `,"value":`...)
+		b = appendJSONValue(b, kv.value)
+		b = append(b, '}')
+	}
+	return append(b, ']')
+}
+
+func appendJSONValue(b []byte, v anyValue) []byte {
+	switch v.kind {
+	case stringValue:
+		b = append(b, `{"stringValue":`...)
+		b = appendJSONString(b, v.str)
+	case boolValue:
+		b = append(b, `{"boolValue":`...)
+		b = strconv.AppendBool(b, v.num == 1)
+	case intValue:
+		b = append(b, `{"intValue":"`...)
+		b = strconv.AppendInt(b, v.num, 10)
+		b = append(b, '"')
+	case doubleValue:
+		b = append(b, `{"doubleValue":`...)
+		switch {
+		case math.IsNaN(v.float):
+			b = append(b, `"NaN"`...)
+		case math.IsInf(v.float, 1):
+			b = append(b, `"Infinity"`...)
+		case math.IsInf(v.float, -1):
+			b = append(b, `"-Infinity"`...)
+		default:
+			b = strconv.AppendFloat(b, v.float, 'g', -1, 64)
+		}
+	case arrayValue:
+		b = append(b, `{"arrayValue":{"values":[`...)
+		for i, item := range v.list {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendJSONValue(b, item)
+		}
+		b = append(b, "]}"...)
+	case kvlistValue:
+		b = append(b, `{"kvlistValue":{"values":`...)
+		b = appendJSONKeyValues(b, v.kvs)
+		b = append(b, '}')
+	case bytesValue:
+		b = append(b, `{"bytesValue":"`...)
+		b = append(b, base64.StdEncoding.EncodeToString(v.bytes)...)
+		b = append(b, '"')
+	default:
+		b = append(b, '{')
+	}
+	return append(b, '}')
+}
+
+func appendJSONString(b []byte, s string) []byte {
+	quoted, _ := json.Marshal(s)
+	return append(b, quoted...)
+}
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// appendProto appends a protobuf ExportLogsServiceRequest.
+func appendProto(b []byte, s scope, records []logRecord) []byte {
+	return appendMessage(b, 1, func(b []byte) []byte { // resource_logs
+		b = appendMessage(b, 1, func(b []byte) []byte { // resource
+			for _, kv := range s.resource {
+				b = appendProtoKeyValue(b, 1, kv)
+			}
+			return b
+		})
+		return appendMessage(b, 2, func(b []byte) []byte { // scope_logs
+			b = appendMessage(b, 1, func(b []byte) []byte { // scope
+				b = appendProtoString(b, 1, s.name)
+				return appendProtoString(b, 2, s.version)
+			})
+			for _, r := range records {
+				r := r
+				b = appendMessage(b, 2, func(b []byte) []byte { // log_records
+					return appendProtoRecord(b, r)
+				})
+			}
+			return b
+		})
+	})
+}
+
+func appendProtoRecord(b []byte, r logRecord) []byte {
+	if r.timeUnixNano != 0 {
+		b = appendTag(b, 1, wireFixed64)
+		b = appendFixed64(b, uint64(r.timeUnixNano))
+	}
+	b = appendTag(b, 2, wireVarint)
+	b = appendUvarint(b, uint64(r.severityNumber))
+	b = appendProtoString(b, 3, r.severityText)
+	if r.body.kind != emptyValue {
+		b = appendMessage(b, 5, func(b []byte) []byte { return appendProtoValue(b, r.body) })
+	}
+	for _, kv := range r.attributes {
+		b = appendProtoKeyValue(b, 6, kv)
+	}
+	if r.traceID != nil {
+		b = appendProtoBytes(b, 9, r.traceID)
+	}
+	if r.spanID != nil {
+		b = appendProtoBytes(b, 10, r.spanID)
+	}
+	b = appendTag(b, 11, wireFixed64)
+	return appendFixed64(b, uint64(r.observedTimeUnixNano))
+}
+
+func appendProtoKeyValue(b []byte, field int, kv keyValue) []byte {
+	return appendMessage(b, field, func(b []byte) []byte {
+		b = appendProtoString(b, 1, kv.key)
+		return appendMessage(b, 2, func(b []byte) []byte { return appendProtoValue(b, kv.value) })
+	})
+}
+
+func appendProtoValue(b []byte, v anyValue) []byte {
+	switch v.kind {
+	case stringValue:
+		b = appendTag(b, 1, wireBytes)
+		b = appendUvarint(b, uint64(len(v.str)))
+		return append(b, v.str...)
+	case boolValue:
+		b = appendTag(b, 2, wireVarint)
+		return append(b, byte(v.num))
+	case intValue:
+		b = appendTag(b, 3, wireVarint)
+		return appendUvarint(b, uint64(v.num))
+	case doubleValue:
+		b = appendTag(b, 4, wireFixed64)
+		return appendFixed64(b, math.Float64bits(v.float))
+	case arrayValue:
+		return appendMessage(b, 5, func(b []byte) []byte {
+			for _, item := range v.list {
+				item := item
+				b = appendMessage(b, 1, func(b []byte) []byte { return appendProtoValue(b, item) })
+			}
+			return b
+		})
+	case kvlistValue:
+		return appendMessage(b, 6, func(b []byte) []byte {
+			for _, kv := range v.kvs {
+				b = appendProtoKeyValue(b, 1, kv)
+			}
+			return b
+		})
+	case bytesValue:
+		b = appendTag(b, 7, wireBytes)
+		b = appendUvarint(b, uint64(len(v.bytes)))
+		return append(b, v.bytes...)
+	}
+	return b
+}
+
+func appendTag(b []byte, field, wire int) []byte {
+	return appendUvarint(b, uint64(field<<3|wire))
+}
+
+// appendProtoString appends a string field, omitting it when empty as proto3
+// does.
+func appendProtoString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendProtoBytes(b []byte, field int, p []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(p)))
+	return append(b, p...)
+}
+
+// appendMessage appends an embedded message field, writing its contents with
+// fn and then inserting the length before them.
+func appendMessage(b []byte, field int, fn func([]byte) []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	start := len(b)
+	b = fn(b)
+	n := len(b) - start
+	var prefix [binary.MaxVarintLen64]byte
+	size := binary.PutUvarint(prefix[:], uint64(n))
+	b = append(b, prefix[:size]...)
+	copy(b[start+size:], b[start:start+n])
+	copy(b[start:], prefix[:size])
+	return b
+}
+
+func appendUvarint(b []byte, n uint64) []byte {
+	for n >= 0x80 {
+		b = append(b, byte(n)|0x80)
+		n >>= 7
+	}
+	return append(b, byte(n))
+}
+
+func appendFixed64(b []byte, n uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], n)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,32 @@
+package otel_test
+
+import (
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/otel"
+)
+
+func ExampleNew() {
+	exporter, err := otel.New(otel.Config{
+		Endpoint:      otel.DefaultEndpoint,
+		Protocol:      otel.Protobuf,
+		Resource:      log.Data{"service.name": "api"},
+		BatchSize:     100,
+		FlushInterval: 5 * time.Second,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer exporter.Close()
+
+	logger := log.New(log.Config{
+		Threshold: log.InfoLevel,
+		Encoder:   exporter,
+	})
+	logger.Log(log.InfoLevel, log.Data{
+		"message":  "user logged in",
+		"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":  "00f067aa0ba902b7",
+	})
+}
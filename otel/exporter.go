@@ -0,0 +1,229 @@
+// Package otel exports log Data as OpenTelemetry LogRecords over OTLP/HTTP,
+// using either the JSON or the protobuf encoding.
+package otel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/internal/logdata"
+)
+
+// DefaultEndpoint is the OTLP/HTTP logs endpoint of a local collector.
+const DefaultEndpoint = "http://localhost:4318/v1/logs"
+
+// Protocol selects the encoding of exported requests.
+type Protocol int
+
+// Protocols supported by the Exporter.
+const (
+	JSON Protocol = iota
+	Protobuf
+)
+
+// Config is used to set up a new Exporter.
+type Config struct {
+	// Endpoint is the URL requests are posted to. The default is
+	// DefaultEndpoint.
+	Endpoint string
+	// Protocol selects OTLP/JSON or protobuf. The default is JSON.
+	Protocol Protocol
+	// Client sends the requests. The default is a client with a ten second
+	// timeout.
+	Client *http.Client
+	// Headers are added to every request, such as for authentication.
+	Headers map[string]string
+	// Resource describes the source of the records, such as
+	// {"service.name": "api"}.
+	Resource log.Data
+	// ScopeName and ScopeVersion identify the instrumentation scope. The
+	// default name is the import path of this package.
+	ScopeName    string
+	ScopeVersion string
+	// BatchSize is the number of records buffered before they are exported.
+	// The default is 1, exporting each record as it is encoded.
+	BatchSize int
+	// FlushInterval exports buffered records periodically when set, so that
+	// records are not held indefinitely while a batch fills. Errors from
+	// periodic exports are passed to ErrorHandler.
+	FlushInterval time.Duration
+	// ErrorHandler is called with errors from periodic exports. The default is
+	// log.DefaultErrorHandler.
+	ErrorHandler func(error)
+}
+
+var _ log.Encoder = &Exporter{}
+
+// Exporter is an Encoder that converts log Data to LogRecords and posts them
+// to an OTLP/HTTP collector. The level becomes the severity, the message the
+// body, trace_id and span_id the trace context, and all other keys
+// attributes.
+type Exporter struct {
+	config Config
+	scope  scope
+
+	mux     sync.Mutex
+	records []logRecord
+	closed  bool
+	// posting tracks exports sent after releasing mux.
+	posting sync.WaitGroup
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates an Exporter with the Config provided.
+func New(c Config) (*Exporter, error) {
+	if c.Protocol != JSON && c.Protocol != Protobuf {
+		return nil, fmt.Errorf("protocol %d is not a valid protocol", c.Protocol)
+	}
+	if c.BatchSize < 0 {
+		return nil, fmt.Errorf("batch size of %d is not a valid batch size", c.BatchSize)
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = DefaultEndpoint
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if c.ScopeName == "" {
+		c.ScopeName = "github.com/PermissionData/log/otel"
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 1
+	}
+	if c.ErrorHandler == nil {
+		c.ErrorHandler = log.DefaultErrorHandler
+	}
+
+	ex := &Exporter{
+		config: c,
+		scope: scope{
+			resource: mapValue(c.Resource, 0).kvs,
+			name:     c.ScopeName,
+			version:  c.ScopeVersion,
+		},
+		done: make(chan struct{}),
+	}
+	if c.FlushInterval > 0 {
+		ex.wg.Add(1)
+		go ex.flushPeriodically()
+	}
+	return ex, nil
+}
+
+// Encode converts v, which must be log.Data or a map[string]interface{}, to a
+// LogRecord, exporting the batch once it is full.
+func (ex *Exporter) Encode(v interface{}) error {
+	data, err := logdata.Assert("otel", v)
+	if err != nil {
+		return err
+	}
+	r := newRecord(data, time.Now())
+
+	ex.mux.Lock()
+	if ex.closed {
+		ex.mux.Unlock()
+		return fmt.Errorf("otel: exporter is closed")
+	}
+	ex.records = append(ex.records, r)
+	if len(ex.records) < ex.config.BatchSize {
+		ex.mux.Unlock()
+		return nil
+	}
+	return ex.export()
+}
+
+// Flush exports any buffered records.
+func (ex *Exporter) Flush() error {
+	ex.mux.Lock()
+	return ex.export()
+}
+
+// Close stops any periodic exports and exports the remaining records.
+func (ex *Exporter) Close() error {
+	ex.mux.Lock()
+	if ex.closed {
+		ex.mux.Unlock()
+		return nil
+	}
+	ex.closed = true
+	close(ex.done)
+	ex.mux.Unlock()
+
+	ex.wg.Wait()
+	err := ex.Flush()
+	ex.posting.Wait()
+	return err
+}
+
+func (ex *Exporter) flushPeriodically() {
+	defer ex.wg.Done()
+	ticker := time.NewTicker(ex.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ex.done:
+			return
+		case <-ticker.C:
+			if err := ex.Flush(); err != nil {
+				ex.config.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+// export encodes the buffered records and posts them once ex.mux, which must
+// be held, is released, so that other loggers are not kept waiting on the
+// collector. The records are dropped even when the export fails, so that a
+// collector outage cannot grow the buffer without bound.
+func (ex *Exporter) export() error {
+	if len(ex.records) == 0 {
+		ex.mux.Unlock()
+		return nil
+	}
+	records := ex.records
+	ex.records = ex.records[:0]
+
+	contentType := "application/json"
+	var b []byte
+	if ex.config.Protocol == Protobuf {
+		contentType = "application/x-protobuf"
+		b = appendProto(b, ex.scope, records)
+	} else {
+		b = appendJSON(b, ex.scope, records)
+	}
+	n := len(records)
+	for i := range records {
+		records[i] = logRecord{}
+	}
+	ex.posting.Add(1)
+	ex.mux.Unlock()
+	defer ex.posting.Done()
+
+	req, err := http.NewRequest(http.MethodPost, ex.config.Endpoint, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("otel: creating request: %+v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range ex.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := ex.config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otel: exporting %d records: %+v", n, err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("otel: exporting %d records: %s: %s", n, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package otel_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+	"github.com/PermissionData/log/otel"
+)
+
+// collector is a stand-in for an OTLP/HTTP collector, recording requests.
+type collector struct {
+	*httptest.Server
+
+	mux      sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+}
+
+func newCollector(t *testing.T) *collector {
+	c := &collector{status: http.StatusOK}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unexpected error reading request: %+v", err)
+		}
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		c.requests = append(c.requests, r)
+		c.bodies = append(c.bodies, body)
+		w.WriteHeader(c.status)
+	}))
+	return c
+}
+
+func (c *collector) count() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.bodies)
+}
+
+var testData = log.Data{
+	log.TimestampKey: "2017-01-02T03:04:05.000Z",
+	log.VersionKey:   "1",
+	log.LevelKey:     log.ErrorLevel,
+	log.MessageKey:   "request failed",
+	otel.TraceIDKey:  "4bf92f3577b34da6a3ce929d0e0e4736",
+	otel.SpanIDKey:   "00f067aa0ba902b7",
+	"error":          errors.New("connection reset"),
+	"status":         502,
+	"ok":             false,
+	"pi":             3.14,
+	"tags":           []string{"a"},
+	"req":            log.Data{"method": "GET"},
+}
+
+func TestExportJSON(t *testing.T) {
+	c := newCollector(t)
+	defer c.Close()
+
+	ex, err := otel.New(otel.Config{
+		Endpoint: c.URL + "/v1/logs",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		Resource: log.Data{"service.name": "api"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating Exporter: %+v", err)
+	}
+	if err := ex.Encode(testData); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+
+	if c.count() != 1 {
+		t.Fatalf("collector received %d requests, expected 1", c.count())
+	}
+	req := c.requests[0]
+	if req.URL.Path != "/v1/logs" || req.Header.Get("Content-Type") != "application/json" || req.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("collector received %s with headers %v", req.URL.Path, req.Header)
+	}
+
+	var got interface{}
+	if err := json.Unmarshal(c.bodies[0], &got); err != nil {
+		t.Fatalf("Exporter sent invalid JSON %s: %+v", c.bodies[0], err)
+	}
+	var want interface{}
+	json.Unmarshal([]byte(`{"resourceLogs":[{
+		"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"api"}}]},
+		"scopeLogs":[{
+			"scope":{"name":"github.com/PermissionData/log/otel"},
+			"logRecords":[{
+				"timeUnixNano":"1483326245000000000",
+				"observedTimeUnixNano":"OBSERVED",
+				"severityNumber":17,
+				"severityText":"Error",
+				"body":{"stringValue":"request failed"},
+				"attributes":[
+					{"key":"error","value":{"stringValue":"connection reset"}},
+					{"key":"ok","value":{"boolValue":false}},
+					{"key":"pi","value":{"doubleValue":3.14}},
+					{"key":"req","value":{"kvlistValue":{"values":[{"key":"method","value":{"stringValue":"GET"}}]}}},
+					{"key":"status","value":{"intValue":"502"}},
+					{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"a"}]}}}
+				],
+				"traceId":"4bf92f3577b34da6a3ce929d0e0e4736",
+				"spanId":"00f067aa0ba902b7"
+			}]
+		}]
+	}]}`), &want)
+
+	record := got.(map[string]interface{})["resourceLogs"].([]interface{})[0].(map[string]interface{})["scopeLogs"].([]interface{})[0].(map[string]interface{})["logRecords"].([]interface{})[0].(map[string]interface{})
+	record["observedTimeUnixNano"] = "OBSERVED"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Exporter sent\n%s\nexpected\n%+v", c.bodies[0], want)
+	}
+}
+
+func TestExportProtobuf(t *testing.T) {
+	c := newCollector(t)
+	defer c.Close()
+
+	ex, err := otel.New(otel.Config{
+		Endpoint: c.URL,
+		Protocol: otel.Protobuf,
+		Resource: log.Data{"service.name": "api"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating Exporter: %+v", err)
+	}
+	if err := ex.Encode(testData); err != nil {
+		t.Fatalf("Encode returned unexpected error: %+v", err)
+	}
+	if c.count() != 1 || c.requests[0].Header.Get("Content-Type") != "application/x-protobuf" {
+		t.Fatalf("collector did not receive a protobuf request")
+	}
+
+	resourceLogs := protoFields(t, c.bodies[0])[1][0]
+	resource := protoFields(t, protoFields(t, resourceLogs)[1][0])
+	if kv := protoFields(t, resource[1][0]); string(kv[1][0]) != "service.name" {
+		t.Errorf("resource attribute has key %q, expected service.name", kv[1][0])
+	}
+	scopeLogs := protoFields(t, protoFields(t, resourceLogs)[2][0])
+	if name := protoFields(t, scopeLogs[1][0])[1][0]; string(name) != "github.com/PermissionData/log/otel" {
+		t.Errorf("scope has name %q", name)
+	}
+	record := protoFields(t, scopeLogs[2][0])
+
+	if ts := binary.LittleEndian.Uint64(record[1][0]); ts != 1483326245000000000 {
+		t.Errorf("record has time %d, expected 1483326245000000000", ts)
+	}
+	if sev, _ := binary.Uvarint(record[2][0]); sev != 17 {
+		t.Errorf("record has severity %d, expected 17", sev)
+	}
+	if text := string(record[3][0]); text != "Error" {
+		t.Errorf("record has severity text %q, expected Error", text)
+	}
+	if body := string(protoFields(t, record[5][0])[1][0]); body != "request failed" {
+		t.Errorf("record has body %q, expected request failed", body)
+	}
+	if n := len(record[6]); n != 6 {
+		t.Errorf("record has %d attributes, expected 6", n)
+	}
+	pi := protoFields(t, protoFields(t, record[6][2])[2][0])
+	if f := math.Float64frombits(binary.LittleEndian.Uint64(pi[4][0])); f != 3.14 {
+		t.Errorf("record has pi attribute %v, expected 3.14", f)
+	}
+	status := protoFields(t, protoFields(t, record[6][4])[2][0])
+	if n, _ := binary.Uvarint(status[3][0]); n != 502 {
+		t.Errorf("record has status attribute %d, expected 502", n)
+	}
+	if len(record[9][0]) != 16 || len(record[10][0]) != 8 {
+		t.Errorf("record has trace ID %x and span ID %x", record[9][0], record[10][0])
+	}
+	if len(record[11][0]) != 8 {
+		t.Errorf("record has no observed time")
+	}
+}
+
+// protoFields decodes the fields of a protobuf message, keeping varints in
+// their encoded form.
+func protoFields(t *testing.T, b []byte) map[int][][]byte {
+	t.Helper()
+	fields := map[int][][]byte{}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("invalid tag in %x", b)
+		}
+		b = b[n:]
+		field := int(tag >> 3)
+		var val []byte
+		switch tag & 7 {
+		case 0:
+			_, n = binary.Uvarint(b)
+			val, b = b[:n], b[n:]
+		case 1:
+			val, b = b[:8], b[8:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			b = b[n:]
+			val, b = b[:size], b[size:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+		fields[field] = append(fields[field], val)
+	}
+	return fields
+}
+
+func TestSeverityNumber(t *testing.T) {
+	testCases := []struct {
+		lvl  log.Level
+		want int
+	}{
+		{log.FatalLevel, 21},
+		{log.ErrorLevel, 17},
+		{log.InfoLevel, 9},
+		{log.TraceLevel, 1},
+		{log.TraceLevel + 1, 1},
+	}
+	for _, tc := range testCases {
+		if got := otel.SeverityNumber(tc.lvl); got != tc.want {
+			t.Errorf("SeverityNumber(%v) = %d, expected %d", tc.lvl, got, tc.want)
+		}
+	}
+}
+
+func TestExportBatches(t *testing.T) {
+	c := newCollector(t)
+	defer c.Close()
+
+	ex, err := otel.New(otel.Config{Endpoint: c.URL, BatchSize: 3})
+	if err != nil {
+		t.Fatalf("unexpected error creating Exporter: %+v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := ex.Encode(log.Data{log.MessageKey: "hello"}); err != nil {
+			t.Fatalf("Encode returned unexpected error: %+v", err)
+		}
+	}
+	if c.count() != 1 || strings.Count(string(c.bodies[0]), `"hello"`) != 3 {
+		t.Fatalf("collector received %d requests, expected one with 3 records", c.count())
+	}
+	if err := ex.Close(); err != nil {
+		t.Fatalf("Close returned unexpected error: %+v", err)
+	}
+	if c.count() != 2 || strings.Count(string(c.bodies[1]), `"hello"`) != 1 {
+		t.Fatalf("Close did not export the remaining record")
+	}
+	if err := ex.Encode(log.Data{}); err == nil {
+		t.Errorf("Encode after Close did not return an error")
+	}
+}
+
+func TestExportDoesNotBlockEncode(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer srv.Close()
+
+	ex, err := otel.New(otel.Config{Endpoint: srv.URL, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error creating Exporter: %+v", err)
+	}
+	exported := make(chan error)
+	go func() {
+		ex.Encode(log.Data{log.MessageKey: "first"})
+		exported <- ex.Encode(log.Data{log.MessageKey: "second"})
+	}()
+	<-received
+
+	encoded := make(chan error)
+	go func() { encoded <- ex.Encode(log.Data{log.MessageKey: "third"}) }()
+	select {
+	case err := <-encoded:
+		if err != nil {
+			t.Errorf("Encode returned unexpected error: %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Encode waited for an export in progress")
+	}
+
+	close(release)
+	if err := <-exported; err != nil {
+		t.Errorf("export returned unexpected error: %+v", err)
+	}
+	if err := ex.Close(); err != nil {
+		t.Errorf("Close returned unexpected error: %+v", err)
+	}
+}
+
+func TestExportFlushInterval(t *testing.T) {
+	c := newCollector(t)
+	defer c.Close()
+
+	ex, err := otel.New(otel.Config{Endpoint: c.URL, BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error creating Exporter: %+v", err)
+	}
+	defer ex.Close()
+	ex.Encode(log.Data{log.MessageKey: "hello"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if c.count() != 1 {
+		t.Fatalf("collector received %d requests, expected 1 from the periodic flush", c.count())
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	c := newCollector(t)
+	defer c.Close()
+	c.status = http.StatusServiceUnavailable
+
+	ex, err := otel.New(otel.Config{Endpoint: c.URL})
+	if err != nil {
+		t.Fatalf("unexpected error creating Exporter: %+v", err)
+	}
+	if err := ex.Encode(log.Data{}); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Encode returned %v, expected a 503 error", err)
+	}
+	if err := ex.Encode("message"); err == nil {
+		t.Errorf("Encode(string) did not return an error")
+	}
+
+	if _, err := otel.New(otel.Config{Protocol: 7}); err == nil {
+		t.Errorf("New with an invalid Protocol did not return an error")
+	}
+	if _, err := otel.New(otel.Config{BatchSize: -1}); err == nil {
+		t.Errorf("New with a negative BatchSize did not return an error")
+	}
+}
+
+func TestExportCycle(t *testing.T) {
+	c := newCollector(t)
+	defer c.Close()
+
+	ex, err := otel.New(otel.Config{Endpoint: c.URL})
+	if err != nil {
+		t.Fatalf("unexpected error creating Exporter: %+v", err)
+	}
+	data := log.Data{log.MessageKey: "hi"}
+	data["self"] = data
+	if err := ex.Encode(data); err != nil {
+		t.Fatalf("Encode(<cycle>) returned unexpected error: %+v", err)
+	}
+	if c.count() != 1 {
+		t.Fatalf("collector received %d requests, expected 1", c.count())
+	}
+	if !strings.Contains(string(c.bodies[0]), "json: unsupported value: ") {
+		t.Errorf("Exporter sent %s, expected the encoding error for the cycle", c.bodies[0])
+	}
+}
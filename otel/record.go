@@ -0,0 +1,291 @@
+package otel
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/PermissionData/log"
+)
+
+// Keys holding the W3C trace context of an entry as hex strings.
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+// SeverityNumber maps a Level to the OpenTelemetry severity number. Levels
+// beyond TraceLevel are mapped to TRACE.
+func SeverityNumber(lvl log.Level) int {
+	switch lvl {
+	case log.FatalLevel:
+		return 21 // FATAL
+	case log.ErrorLevel:
+		return 17 // ERROR
+	case log.InfoLevel:
+		return 9 // INFO
+	}
+	return 1 // TRACE
+}
+
+type valueKind uint8
+
+const (
+	emptyValue valueKind = iota
+	stringValue
+	boolValue
+	intValue
+	doubleValue
+	arrayValue
+	kvlistValue
+	bytesValue
+)
+
+// anyValue is the OTLP AnyValue, holding one of the kinds above.
+type anyValue struct {
+	kind  valueKind
+	str   string
+	num   int64
+	float float64
+	list  []anyValue
+	kvs   []keyValue
+	bytes []byte
+}
+
+type keyValue struct {
+	key   string
+	value anyValue
+}
+
+// logRecord is the OTLP LogRecord built from an entry.
+type logRecord struct {
+	timeUnixNano         int64
+	observedTimeUnixNano int64
+	severityNumber       int
+	severityText         string
+	body                 anyValue
+	attributes           []keyValue
+	traceID              []byte
+	spanID               []byte
+}
+
+// newRecord maps data to a LogRecord. The level, timestamp, message, and trace
+// context become fields of the record, and all other keys become attributes.
+func newRecord(data log.Data, observed time.Time) logRecord {
+	r := logRecord{observedTimeUnixNano: observed.UnixNano()}
+
+	lvl := log.InfoLevel
+	switch val := data[log.LevelKey].(type) {
+	case log.Level:
+		lvl = val
+	case string:
+		lvl.UnmarshalText([]byte(val))
+	}
+	r.severityNumber = SeverityNumber(lvl)
+	r.severityText = lvl.String()
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := data[k]
+		switch k {
+		case log.VersionKey, log.LevelKey:
+			continue
+		case log.TimestampKey:
+			if t, ok := timestamp(v); ok {
+				r.timeUnixNano = t.UnixNano()
+				continue
+			}
+		case log.MessageKey:
+			r.body = newValue(v, 0)
+			continue
+		case log.StackKey:
+			r.attributes = append(r.attributes, keyValue{"exception.stacktrace", newValue(v, 0)})
+			continue
+		case TraceIDKey:
+			if id, ok := hexID(v, 16); ok {
+				r.traceID = id
+				continue
+			}
+		case SpanIDKey:
+			if id, ok := hexID(v, 8); ok {
+				r.spanID = id
+				continue
+			}
+		}
+		r.attributes = append(r.attributes, keyValue{k, newValue(v, 0)})
+	}
+	return r
+}
+
+func timestamp(v interface{}) (time.Time, bool) {
+	switch ts := v.(type) {
+	case time.Time:
+		return ts, true
+	case string:
+		if t, err := time.Parse(log.DefaultTimestampFormat, ts); err == nil {
+			return t, true
+		}
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// hexID decodes a trace or span ID of n bytes, rejecting invalid and all-zero
+// IDs.
+func hexID(v interface{}, n int) ([]byte, bool) {
+	s, ok := v.(string)
+	if !ok || len(s) != 2*n {
+		return nil, false
+	}
+	id, err := hex.DecodeString(s)
+	if err != nil || bytes.Equal(id, make([]byte, n)) {
+		return nil, false
+	}
+	return id, true
+}
+
+// maxDepth is how deeply newValue recurses into nested values before
+// converting them through encoding/json, which fails on values containing
+// themselves.
+const maxDepth = 100
+
+func newValue(v interface{}, depth int) anyValue {
+	if depth > maxDepth {
+		return jsonValue(v)
+	}
+	switch val := v.(type) {
+	case nil:
+		return anyValue{}
+	case string:
+		return anyValue{kind: stringValue, str: val}
+	case bool:
+		if val {
+			return anyValue{kind: boolValue, num: 1}
+		}
+		return anyValue{kind: boolValue}
+	case int:
+		return anyValue{kind: intValue, num: int64(val)}
+	case int8:
+		return anyValue{kind: intValue, num: int64(val)}
+	case int16:
+		return anyValue{kind: intValue, num: int64(val)}
+	case int32:
+		return anyValue{kind: intValue, num: int64(val)}
+	case int64:
+		return anyValue{kind: intValue, num: val}
+	case uint:
+		return uintValue(uint64(val))
+	case uint8:
+		return anyValue{kind: intValue, num: int64(val)}
+	case uint16:
+		return anyValue{kind: intValue, num: int64(val)}
+	case uint32:
+		return anyValue{kind: intValue, num: int64(val)}
+	case uint64:
+		return uintValue(val)
+	case float32:
+		return anyValue{kind: doubleValue, float: float64(val)}
+	case float64:
+		return anyValue{kind: doubleValue, float: val}
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			return anyValue{kind: intValue, num: n}
+		}
+		if f, err := val.Float64(); err == nil {
+			return anyValue{kind: doubleValue, float: f}
+		}
+		return anyValue{kind: stringValue, str: val.String()}
+	case []byte:
+		return anyValue{kind: bytesValue, bytes: val}
+	case time.Time:
+		return anyValue{kind: stringValue, str: val.Format(time.RFC3339Nano)}
+	case time.Duration:
+		return anyValue{kind: intValue, num: int64(val)}
+	case log.Lazy:
+		if val == nil {
+			return anyValue{}
+		}
+		return newValue(val(), depth+1)
+	case log.Data:
+		return mapValue(val, depth)
+	case map[string]interface{}:
+		return mapValue(val, depth)
+	case []interface{}:
+		list := make([]anyValue, len(val))
+		for i, item := range val {
+			list[i] = newValue(item, depth+1)
+		}
+		return anyValue{kind: arrayValue, list: list}
+	case json.Marshaler:
+	case encoding.TextMarshaler:
+		if b, err := val.MarshalText(); err == nil {
+			return anyValue{kind: stringValue, str: string(b)}
+		}
+	case error:
+		return anyValue{kind: stringValue, str: val.Error()}
+	case fmt.Stringer:
+		return anyValue{kind: stringValue, str: val.String()}
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		if _, ok := v.(json.Marshaler); !ok {
+			list := make([]anyValue, rv.Len())
+			for i := range list {
+				list[i] = newValue(rv.Index(i).Interface(), depth+1)
+			}
+			return anyValue{kind: arrayValue, list: list}
+		}
+	}
+
+	return jsonValue(v)
+}
+
+// jsonValue converts v to what its JSON encoding would decode as, or to the
+// error text when it has none.
+func jsonValue(v interface{}) anyValue {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return anyValue{kind: stringValue, str: err.Error()}
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return anyValue{kind: stringValue, str: string(b)}
+	}
+	return newValue(decoded, 0)
+}
+
+func uintValue(n uint64) anyValue {
+	if n > math.MaxInt64 {
+		return anyValue{kind: stringValue, str: strconv.FormatUint(n, 10)}
+	}
+	return anyValue{kind: intValue, num: int64(n)}
+}
+
+func mapValue(m map[string]interface{}, depth int) anyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]keyValue, len(keys))
+	for i, k := range keys {
+		kvs[i] = keyValue{k, newValue(m[k], depth+1)}
+	}
+	return anyValue{kind: kvlistValue, kvs: kvs}
+}
@@ -0,0 +1,187 @@
+package log
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// SanitizeFilter provides a Filter that rewrites values json.Encoder cannot
+// encode so that the rest of the entry survives. Channels, funcs, and other
+// unencodable values are replaced with descriptive placeholders, NaN and
+// infinite floats become strings, and cycles are broken. Durations, errors,
+// and fmt.Stringers become strings, and byte slices become strings when they
+// are valid UTF-8. Lazy values are resolved, so SanitizeFilter should come
+// after any Filter that may discard the entry.
+func SanitizeFilter() Filter {
+	return func(lvl, threshold Level, data Data) Data {
+		if data == nil {
+			return nil
+		}
+		s := &sanitizer{seen: map[uintptr]bool{}}
+		out := make(Data, len(data))
+		for k, v := range data {
+			out[k] = s.value(v)
+		}
+		return out
+	}
+}
+
+type sanitizer struct {
+	seen map[uintptr]bool
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (s *sanitizer) value(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil, bool, string, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, json.Number, Level:
+		return v
+	case float64:
+		return sanitizeFloat(val)
+	case float32:
+		return sanitizeFloat(float64(val))
+	case Lazy:
+		return s.value(val.resolve())
+	case time.Time:
+		return v
+	case time.Duration:
+		return val.String()
+	case []byte:
+		if utf8.Valid(val) {
+			return string(val)
+		}
+		return base64.StdEncoding.EncodeToString(val)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return fmt.Sprintf("<%s>", rv.Type())
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+
+	if rv.Type().Implements(jsonMarshalerType) || rv.Type().Implements(textMarshalerType) {
+		return v
+	}
+	switch val := v.(type) {
+	case error:
+		return val.Error()
+	case fmt.Stringer:
+		return val.String()
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if s.enter(rv) {
+			return "<cycle>"
+		}
+		defer s.leave(rv)
+		return s.value(rv.Elem().Interface())
+	case reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return s.value(rv.Elem().Interface())
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		if s.enter(rv) {
+			return "<cycle>"
+		}
+		defer s.leave(rv)
+		out := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			out[mapKey(iter.Key())] = s.value(iter.Value().Interface())
+		}
+		return out
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil
+		}
+		if s.enter(rv) {
+			return "<cycle>"
+		}
+		defer s.leave(rv)
+		fallthrough
+	case reflect.Array:
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = s.value(rv.Index(i).Interface())
+		}
+		return out
+	case reflect.Struct:
+		out := make(map[string]interface{})
+		for _, f := range structFields(rv) {
+			out[f.name] = s.value(f.value.Interface())
+		}
+		return out
+	case reflect.Float32, reflect.Float64:
+		return sanitizeFloat(rv.Float())
+	}
+	return v
+}
+
+// enter records a reference while its contents are sanitized, reporting
+// whether it is already being sanitized further up, which means a cycle.
+func (s *sanitizer) enter(rv reflect.Value) bool {
+	ptr := rv.Pointer()
+	if ptr == 0 {
+		return false
+	}
+	if s.seen[ptr] {
+		return true
+	}
+	s.seen[ptr] = true
+	return false
+}
+
+func (s *sanitizer) leave(rv reflect.Value) {
+	delete(s.seen, rv.Pointer())
+}
+
+func sanitizeFloat(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return f
+}
+
+// mapKey converts a map key to the string encoding/json would use.
+func mapKey(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if b, err := tm.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	return fmt.Sprint(k.Interface())
+}
@@ -0,0 +1,166 @@
+package log_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PermissionData/log"
+)
+
+type node struct {
+	Name string `json:"name"`
+	Next *node  `json:"next"`
+}
+
+type stringer struct{}
+
+func (stringer) String() string { return "stringer" }
+
+func TestSanitizeFilter(t *testing.T) {
+	ch := make(chan int)
+	when := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cyclic := &node{Name: "a"}
+	cyclic.Next = &node{Name: "b", Next: cyclic}
+
+	selfMap := map[string]interface{}{"name": "self"}
+	selfMap["self"] = selfMap
+
+	shared := log.Data{"x": 1}
+
+	testCases := []struct {
+		name     string
+		inData   log.Data
+		wantData log.Data
+	}{
+		{"nil data",
+			nil,
+			nil,
+		},
+		{"encodable values",
+			log.Data{
+				"pi":    3.14,
+				"foo":   "bar",
+				"n":     42,
+				"ok":    true,
+				"nil":   nil,
+				"level": log.InfoLevel,
+				"when":  when,
+			},
+			log.Data{
+				"pi":    3.14,
+				"foo":   "bar",
+				"n":     42,
+				"ok":    true,
+				"nil":   nil,
+				"level": log.InfoLevel,
+				"when":  when,
+			},
+		},
+		{"unencodable values",
+			log.Data{
+				"chan":    ch,
+				"func":    func() {},
+				"complex": complex(1, 2),
+			},
+			log.Data{
+				"chan":    "<chan int>",
+				"func":    "<func()>",
+				"complex": "<complex128>",
+			},
+		},
+		{"special floats",
+			log.Data{
+				"nan":    math.NaN(),
+				"inf":    math.Inf(1),
+				"neginf": float32(math.Inf(-1)),
+			},
+			log.Data{
+				"nan":    "NaN",
+				"inf":    "+Inf",
+				"neginf": "-Inf",
+			},
+		},
+		{"conversions",
+			log.Data{
+				"duration": 1500 * time.Millisecond,
+				"text":     []byte("hello"),
+				"binary":   []byte{0xff, 0xfe},
+				"error":    fmt.Errorf("oops"),
+				"stringer": stringer{},
+				"ip":       net.IPv4(127, 0, 0, 1),
+				"lazy":     log.Lazy(func() interface{} { return time.Second }),
+			},
+			log.Data{
+				"duration": "1.5s",
+				"text":     "hello",
+				"binary":   "//4=",
+				"error":    "oops",
+				"stringer": "stringer",
+				"ip":       net.IPv4(127, 0, 0, 1),
+				"lazy":     "1s",
+			},
+		},
+		{"nested values",
+			log.Data{
+				"nested": log.Data{"chan": ch, "list": []interface{}{math.NaN()}},
+				"map":    map[int]float64{1: math.Inf(1)},
+			},
+			log.Data{
+				"nested": map[string]interface{}{"chan": "<chan int>", "list": []interface{}{"NaN"}},
+				"map":    map[string]interface{}{"1": "+Inf"},
+			},
+		},
+		{"cycles",
+			log.Data{
+				"list": cyclic,
+				"map":  selfMap,
+			},
+			log.Data{
+				"list": map[string]interface{}{
+					"name": "a",
+					"next": map[string]interface{}{
+						"name": "b",
+						"next": "<cycle>",
+					},
+				},
+				"map": map[string]interface{}{
+					"name": "self",
+					"self": "<cycle>",
+				},
+			},
+		},
+		{"shared references are not cycles",
+			log.Data{"a": []interface{}{shared, shared}},
+			log.Data{"a": []interface{}{
+				map[string]interface{}{"x": 1},
+				map[string]interface{}{"x": 1},
+			}},
+		},
+		{"nil pointers",
+			log.Data{"node": (*node)(nil), "err": (*net.OpError)(nil)},
+			log.Data{"node": nil, "err": nil},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			gotData := log.SanitizeFilter()(log.InfoLevel, log.InfoLevel, tc.inData)
+			if !reflect.DeepEqual(gotData, tc.wantData) {
+				t.Fatalf("SanitizeFilter()(InfoLevel, InfoLevel, %+v) = %+v, expected %+v", tc.inData, gotData, tc.wantData)
+			}
+			if gotData == nil {
+				return
+			}
+			if _, err := json.Marshal(gotData); err != nil {
+				t.Fatalf("json.Marshal(SanitizeFilter()(...)) returned unexpected error: %+v", err)
+			}
+		})
+	}
+}
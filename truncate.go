@@ -0,0 +1,291 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// TruncateConfig sets the limits enforced by TruncateFilter. Zero values mean
+// no limit.
+type TruncateConfig struct {
+	// MaxStringLength caps the length of strings in bytes.
+	MaxStringLength int
+	// MaxBytesLength caps the length of byte slices.
+	MaxBytesLength int
+	// MaxKeys caps the number of keys in Data and nested maps.
+	MaxKeys int
+	// MaxCollectionLength caps the number of elements in slices and arrays.
+	MaxCollectionLength int
+	// MaxSize caps the estimated size of the JSON encoded Data. The largest
+	// fields are shortened or dropped until the Data fits.
+	MaxSize int
+	// Keep lists top-level keys that are never dropped. The default is the
+	// keys added by BaseFilter.
+	Keep []string
+	// TruncatedKey is the key used to list the fields that were cut. The
+	// default is "_truncated".
+	TruncatedKey string
+}
+
+// DefaultTruncatedKey is used to mark cut fields when no TruncatedKey is
+// configured.
+const DefaultTruncatedKey = "_truncated"
+
+// TruncateFilter provides a Filter that caps the size of log Data so that a
+// single large value cannot make the whole entry unwritable. The paths of any
+// fields that were cut are listed under the TruncatedKey.
+func TruncateFilter(c TruncateConfig) Filter {
+	if c.TruncatedKey == "" {
+		c.TruncatedKey = DefaultTruncatedKey
+	}
+	if c.Keep == nil {
+		c.Keep = []string{TimestampKey, VersionKey, LevelKey}
+	}
+	keep := make(map[string]bool, len(c.Keep)+1)
+	for _, k := range c.Keep {
+		keep[k] = true
+	}
+	keep[c.TruncatedKey] = true
+
+	return func(lvl, threshold Level, data Data) Data {
+		if data == nil {
+			return nil
+		}
+		tr := &truncator{config: c, keep: keep}
+		data = tr.data(data)
+		if c.MaxSize > 0 {
+			tr.fit(data)
+		}
+		if len(tr.cut) > 0 {
+			sort.Strings(tr.cut)
+			data[c.TruncatedKey] = tr.cut
+		}
+		return data
+	}
+}
+
+type truncator struct {
+	config TruncateConfig
+	keep   map[string]bool
+	cut    []string
+}
+
+func (tr *truncator) data(data Data) Data {
+	keys := tr.keys(data, "")
+	out := make(Data, len(keys))
+	for _, k := range keys {
+		out[k] = tr.value(k, data[k], nil)
+	}
+	return out
+}
+
+// keys returns the keys of data that fit within MaxKeys, preferring kept keys
+// and otherwise sorted order.
+func (tr *truncator) keys(data Data, path string) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	max := tr.config.MaxKeys
+	if max <= 0 || len(keys) <= max {
+		return keys
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if path == "" && tr.keep[keys[i]] != tr.keep[keys[j]] {
+			return tr.keep[keys[i]]
+		}
+		return keys[i] < keys[j]
+	})
+	if path != "" {
+		tr.cut = append(tr.cut, path)
+		return keys[:max]
+	}
+	for _, k := range keys[max:] {
+		if tr.keep[k] {
+			max++
+			continue
+		}
+		tr.cut = append(tr.cut, k)
+	}
+	return keys[:max]
+}
+
+func (tr *truncator) value(path string, v interface{}, parents []uintptr) interface{} {
+	c := tr.config
+	switch v.(type) {
+	case Data, map[string]interface{}, []interface{}:
+		var ok bool
+		if parents, ok = enter(parents, v); !ok {
+			// a value containing itself can never be written
+			tr.cut = append(tr.cut, path)
+			return nil
+		}
+	}
+
+	switch val := v.(type) {
+	case nil, bool, int, int64, float64, Level:
+		return v
+	case string:
+		if c.MaxStringLength > 0 && len(val) > c.MaxStringLength {
+			tr.cut = append(tr.cut, path)
+			return truncateString(val, c.MaxStringLength)
+		}
+		return v
+	case []byte:
+		if c.MaxBytesLength > 0 && len(val) > c.MaxBytesLength {
+			tr.cut = append(tr.cut, path)
+			return val[:c.MaxBytesLength]
+		}
+		return v
+	case Data:
+		nested := make(Data, len(val))
+		for _, k := range tr.keys(val, path) {
+			nested[k] = tr.value(path+"."+k, val[k], parents)
+		}
+		return nested
+	case map[string]interface{}:
+		nested := make(map[string]interface{}, len(val))
+		for _, k := range tr.keys(Data(val), path) {
+			nested[k] = tr.value(path+"."+k, val[k], parents)
+		}
+		return nested
+	case []interface{}:
+		n := len(val)
+		if c.MaxCollectionLength > 0 && n > c.MaxCollectionLength {
+			tr.cut = append(tr.cut, path)
+			n = c.MaxCollectionLength
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = tr.value(path+"."+strconv.Itoa(i), val[i], parents)
+		}
+		return list
+	}
+
+	if c.MaxCollectionLength <= 0 {
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice && rv.Len() > c.MaxCollectionLength {
+		tr.cut = append(tr.cut, path)
+		return rv.Slice(0, c.MaxCollectionLength).Interface()
+	}
+	return v
+}
+
+// fit shortens or drops the largest top-level fields until the estimated size
+// of data is within MaxSize.
+func (tr *truncator) fit(data Data) {
+	sizes := make(map[string]int, len(data))
+	total := 2 // braces
+	for k, v := range data {
+		sizes[k] = fieldSize(k, v)
+		total += sizes[k]
+	}
+	// reserve room for the marker itself
+	total += fieldSize(tr.config.TruncatedKey, tr.cut)
+
+	for total > tr.config.MaxSize {
+		largest := ""
+		for k, size := range sizes {
+			if tr.keep[k] {
+				continue
+			}
+			if largest == "" || size > sizes[largest] || (size == sizes[largest] && k < largest) {
+				largest = k
+			}
+		}
+		if largest == "" {
+			return
+		}
+		tr.cut = append(tr.cut, largest)
+		total += estimateSize(largest) + 1
+
+		size := sizes[largest]
+		delete(sizes, largest) // never shorten the same field twice
+		excess := total - tr.config.MaxSize
+		if s, ok := data[largest].(string); ok && len(s) > excess {
+			data[largest] = truncateString(s, len(s)-excess)
+			total += fieldSize(largest, data[largest]) - size
+			continue
+		}
+		delete(data, largest)
+		total -= size
+	}
+}
+
+func fieldSize(k string, v interface{}) int {
+	return estimateSize(k) + 1 + estimateSize(v) + 1
+}
+
+// truncateString shortens s to at most n bytes without splitting a rune.
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// estimateSize approximates the length of the JSON encoding of v without
+// encoding common types.
+func estimateSize(v interface{}) int {
+	switch val := v.(type) {
+	case nil:
+		return 4
+	case bool:
+		if val {
+			return 4
+		}
+		return 5
+	case string:
+		return len(val) + 2
+	case []byte:
+		return (len(val)+2)/3*4 + 2
+	case int:
+		return len(strconv.Itoa(val))
+	case int64:
+		return len(strconv.FormatInt(val, 10))
+	case float64:
+		return len(strconv.FormatFloat(val, 'g', -1, 64))
+	case Level:
+		return len(val.String()) + 2
+	case Data:
+		return estimateMapSize(val)
+	case map[string]interface{}:
+		return estimateMapSize(val)
+	case []interface{}:
+		size := 2
+		for _, item := range val {
+			size += estimateSize(item) + 1
+		}
+		return size
+	case []string:
+		size := 2
+		for _, item := range val {
+			size += len(item) + 3
+		}
+		return size
+	case error:
+		return len(val.Error()) + 2
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return len(fmt.Sprint(v)) + 2
+	}
+	return len(b)
+}
+
+func estimateMapSize(m map[string]interface{}) int {
+	size := 2
+	for k, v := range m {
+		size += len(k) + 3 + estimateSize(v) + 1
+	}
+	return size
+}
@@ -0,0 +1,191 @@
+package log_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/PermissionData/log"
+)
+
+func TestTruncateFilter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   log.TruncateConfig
+		inData   log.Data
+		wantData log.Data
+	}{
+		{"nil data",
+			log.TruncateConfig{MaxStringLength: 1},
+			nil,
+			nil,
+		},
+		{"no limits",
+			log.TruncateConfig{},
+			log.Data{"body": strings.Repeat("a", 100)},
+			log.Data{"body": strings.Repeat("a", 100)},
+		},
+		{"long strings",
+			log.TruncateConfig{MaxStringLength: 3},
+			log.Data{
+				"body":  "abcdef",
+				"short": "abc",
+				"req":   log.Data{"path": "/a/b/c"},
+			},
+			log.Data{
+				"body":       "abc",
+				"short":      "abc",
+				"req":        log.Data{"path": "/a/"},
+				"_truncated": []string{"body", "req.path"},
+			},
+		},
+		{"strings cut on rune boundary",
+			log.TruncateConfig{MaxStringLength: 4},
+			log.Data{"body": "aéééé"},
+			log.Data{"body": "aé", "_truncated": []string{"body"}},
+		},
+		{"long bytes",
+			log.TruncateConfig{MaxBytesLength: 2, TruncatedKey: "cut"},
+			log.Data{"raw": []byte("abcd")},
+			log.Data{"raw": []byte("ab"), "cut": []string{"raw"}},
+		},
+		{"too many keys",
+			log.TruncateConfig{MaxKeys: 2},
+			log.Data{
+				"log_level": log.InfoLevel,
+				"a":         1,
+				"b":         2,
+				"c":         3,
+				"nested":    map[string]interface{}{"x": 1, "y": 2, "z": 3},
+			},
+			log.Data{
+				"log_level":  log.InfoLevel,
+				"a":          1,
+				"_truncated": []string{"b", "c", "nested"},
+			},
+		},
+		{"too many nested keys",
+			log.TruncateConfig{MaxKeys: 2},
+			log.Data{
+				"nested": map[string]interface{}{"x": 1, "y": 2, "z": 3},
+			},
+			log.Data{
+				"nested":     map[string]interface{}{"x": 1, "y": 2},
+				"_truncated": []string{"nested"},
+			},
+		},
+		{"long collections",
+			log.TruncateConfig{MaxCollectionLength: 2},
+			log.Data{
+				"list":  []interface{}{1, 2, 3},
+				"ints":  []int{1, 2, 3},
+				"short": []interface{}{1},
+			},
+			log.Data{
+				"list":       []interface{}{1, 2},
+				"ints":       []int{1, 2},
+				"short":      []interface{}{1},
+				"_truncated": []string{"ints", "list"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			gotData := log.TruncateFilter(tc.config)(log.InfoLevel, log.InfoLevel, tc.inData)
+			if !reflect.DeepEqual(gotData, tc.wantData) {
+				t.Fatalf("TruncateFilter(%+v)(InfoLevel, InfoLevel, %+v) = %+v, expected %+v", tc.config, tc.inData, gotData, tc.wantData)
+			}
+		})
+	}
+}
+
+func TestTruncateFilterMaxSize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		maxSize  int
+		inData   log.Data
+		wantKeys []string
+		wantCut  []string
+		wantFits bool
+	}{
+		{"fits",
+			1000,
+			log.Data{"log_level": log.InfoLevel, "body": "short"},
+			[]string{"body", "log_level"},
+			nil,
+			true,
+		},
+		{"shortens the largest string",
+			200,
+			log.Data{"log_level": log.InfoLevel, "body": strings.Repeat("a", 1000), "pi": 3.14},
+			[]string{"_truncated", "body", "log_level", "pi"},
+			[]string{"body"},
+			true,
+		},
+		{"drops the largest value",
+			200,
+			log.Data{
+				"log_level": log.InfoLevel,
+				"body":      log.Data{"content": strings.Repeat("a", 1000)},
+				"pi":        3.14,
+			},
+			[]string{"_truncated", "log_level", "pi"},
+			[]string{"body"},
+			true,
+		},
+		{"never drops kept keys",
+			10,
+			log.Data{"@timestamp": strings.Repeat("a", 100), "pi": 3.14},
+			[]string{"@timestamp", "_truncated"},
+			[]string{"pi"},
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			gotData := log.TruncateFilter(log.TruncateConfig{MaxSize: tc.maxSize})(log.InfoLevel, log.InfoLevel, tc.inData)
+
+			var gotKeys []string
+			for k := range gotData {
+				gotKeys = append(gotKeys, k)
+			}
+			sort.Strings(gotKeys)
+			if !reflect.DeepEqual(gotKeys, tc.wantKeys) {
+				t.Fatalf("TruncateFilter(MaxSize: %d) kept keys %v, expected %v", tc.maxSize, gotKeys, tc.wantKeys)
+			}
+			if cut, _ := gotData["_truncated"].([]string); !reflect.DeepEqual(cut, tc.wantCut) {
+				t.Fatalf("TruncateFilter(MaxSize: %d) marked %v as truncated, expected %v", tc.maxSize, cut, tc.wantCut)
+			}
+			if !tc.wantFits {
+				return
+			}
+			b, err := json.Marshal(gotData)
+			if err != nil {
+				t.Fatalf("unexpected error marshaling truncated data: %+v", err)
+			}
+			if len(b) > tc.maxSize {
+				t.Fatalf("TruncateFilter(MaxSize: %d) produced %d bytes: %s", tc.maxSize, len(b), b)
+			}
+		})
+	}
+}
+
+func TestTruncateFilterCycle(t *testing.T) {
+	data := log.Data{"a": 1}
+	data["x"] = data
+	data["y"] = []interface{}{data}
+
+	got := log.TruncateFilter(log.TruncateConfig{})(log.InfoLevel, log.InfoLevel, data)
+	if cut := got[log.DefaultTruncatedKey]; !reflect.DeepEqual(cut, []string{"x.x", "x.y.0", "y.0.x", "y.0.y"}) {
+		t.Errorf("TruncateFilter cut %v, expected the repeated values", cut)
+	}
+	if _, err := json.Marshal(got); err != nil {
+		t.Errorf("TruncateFilter returned Data that cannot be encoded: %+v", err)
+	}
+}